@@ -3,4 +3,9 @@
 # Run from project root
 # demo: bash scripts/run-dev.sh
 
-BUILD_ENV=development docker-compose up
\ No newline at end of file
+# docker secrets, see Secrets in the README
+mkdir -p secrets
+[ -f secrets/db_password ] || openssl rand -hex 24 > secrets/db_password
+[ -f secrets/jwt_secret ] || openssl rand -hex 32 > secrets/jwt_secret
+
+SERVER_PROFILE=dev BUILD_ENV=development docker-compose up
\ No newline at end of file
@@ -0,0 +1,125 @@
+#!/bin/bash
+
+# Checks the row level security policies against a throwaway postgres container.
+# Migrations and policies are applied as the owner, everything else connects
+# as funcedup_app, a plain role like the one the app uses in production, and
+# goes through the application code: the seed and user commands and the
+# tenancy tests of pkg/pgconn. The database tests of the domains run against
+# the container as well.
+# Run from project root, needs docker and go.
+# demo: bash scripts/test-rls.sh
+
+set -euo pipefail
+
+CONTAINER=funcedup-rls-test
+PORT=${RLS_TEST_PORT:-55432}
+BIN=$(mktemp -d)/funcedup
+APP_USER=funcedup_app
+APP_PASSWORD=funcedup_app
+
+docker run -d --rm --name "$CONTAINER" -e POSTGRES_PASSWORD=postgres -p "$PORT:5432" postgres:16 >/dev/null
+trap 'docker stop "$CONTAINER" >/dev/null' EXIT
+
+until docker exec "$CONTAINER" pg_isready -U postgres >/dev/null 2>&1; do sleep 1; done
+
+(cd server && go build -o "$BIN" .)
+
+export SERVER_DATABASE_HOST=localhost
+export SERVER_DATABASE_PORT=$PORT
+export SERVER_DATABASE_SSLMODE=disable
+export SERVER_DATABASE_TENANCY=row
+export SERVER_GLOBAL_LOG_LEVEL=prod
+
+cd server
+"$BIN" migrate up
+"$BIN" tenant create acme
+"$BIN" tenant create globex
+"$BIN" rls apply
+"$BIN" rls verify
+
+sql() {
+	docker exec -i "$CONTAINER" psql -v ON_ERROR_STOP=1 -qtA -U postgres -d postgres -c "$*"
+}
+
+# postgres ignores policies for superusers, the app connects as a plain role
+sql "CREATE ROLE $APP_USER LOGIN PASSWORD '$APP_PASSWORD';
+	GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO $APP_USER;
+	GRANT USAGE ON ALL SEQUENCES IN SCHEMA public TO $APP_USER"
+
+app() {
+	SERVER_DATABASE_USER=$APP_USER \
+		SERVER_DATABASE_PASSWORD=$APP_PASSWORD \
+		SERVER_DATABASE_ROW_LEVEL_SECURITY=true \
+		"$BIN" "$@"
+}
+
+FAILED=0
+ok() {
+	echo "ok   $1"
+}
+fail() {
+	echo "FAIL $1"
+	FAILED=1
+}
+
+# every statement of a command runs in a transaction carrying its tenant,
+# without one the policies would reject the writes
+if app seed --profile demo --tenant acme && app seed --profile minimal --tenant globex; then
+	ok "seed as $APP_USER"
+else
+	fail "seed as $APP_USER"
+fi
+if echo "correct-horse" | app user create --tenant acme --username jane --email jane@example.com --password-stdin; then
+	ok "user create as $APP_USER"
+else
+	fail "user create as $APP_USER"
+fi
+if app user promote --tenant globex jane 2>/dev/null; then
+	fail "globex cannot promote a user of acme"
+else
+	ok "globex cannot promote a user of acme"
+fi
+
+ACME=$(sql "SELECT id FROM tenants WHERE slug = 'acme'")
+GLOBEX=$(sql "SELECT id FROM tenants WHERE slug = 'globex'")
+
+expect() {
+	local name=$1 expected=$2 query=$3
+	local actual
+	actual=$(sql "$query")
+	if [ "$actual" == "$expected" ]; then
+		ok "$name"
+	else
+		fail "$name: expected '$expected', got '$actual'"
+	fi
+}
+
+expect "acme owns its users" "4" "SELECT count(*) FROM users WHERE tenant_id = '$ACME'"
+expect "globex owns its users" "3" "SELECT count(*) FROM users WHERE tenant_id = '$GLOBEX'"
+expect "acme owns its content" "t" "SELECT count(*) > 0 FROM contents WHERE tenant_id = '$ACME'"
+expect "globex has no content" "0" "SELECT count(*) FROM contents WHERE tenant_id = '$GLOBEX'"
+expect "no rows without a tenant" "0" "SELECT (SELECT count(*) FROM users WHERE tenant_id IS NULL) + (SELECT count(*) FROM contents WHERE tenant_id IS NULL)"
+
+# the test tables are created by the owner, the checks connect as the app
+if SERVER_TEST_DATABASE_HOST=localhost \
+	SERVER_TEST_DATABASE_PORT=$PORT \
+	SERVER_TEST_DATABASE_PASSWORD=postgres \
+	SERVER_TEST_DATABASE_APP_USER=$APP_USER \
+	SERVER_TEST_DATABASE_APP_PASSWORD=$APP_PASSWORD \
+	go test -count=1 ./pkg/pgconn/; then
+	ok "pkg/pgconn tenancy tests"
+else
+	fail "pkg/pgconn tenancy tests"
+fi
+
+# the domain tests migrate a schema of their own, see internal/schema/schematest
+if SERVER_TEST_DATABASE_HOST=localhost \
+	SERVER_TEST_DATABASE_PORT=$PORT \
+	SERVER_TEST_DATABASE_PASSWORD=postgres \
+	go test -count=1 ./internal/...; then
+	ok "domain database tests"
+else
+	fail "domain database tests"
+fi
+
+exit $FAILED
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"funcedup/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config print [--flat]: prints the configuration merged from files,
+// environment and overrides, each value commented with its source, secrets
+// redacted. Module defaults are only registered once a module is constructed
+// and are therefore not part of the output, GET /api/v1/admin/config of a
+// running server includes them.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("config needs a subcommand: print")
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	flat := flags.Bool("flat", false, "print one key per line instead of the tree")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	settings := config.Effective()
+	if *flat {
+		for _, s := range settings {
+			fmt.Printf("%s = %v (%s)\n", s.Key, s.Value, s.Source)
+		}
+		return nil
+	}
+
+	// indented like config.yaml
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settingsNode(config.Tree(settings))); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// settingsNode turns a config.Tree into yaml, with the source of each value
+// as a line comment.
+func settingsNode(tree map[string]any) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+
+		var valueNode *yaml.Node
+		switch v := tree[key].(type) {
+		case map[string]any:
+			valueNode = settingsNode(v)
+		case config.Setting:
+			valueNode = &yaml.Node{}
+			if err := valueNode.Encode(v.Value); err != nil {
+				valueNode = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v.Value)}
+			}
+			comment := "# " + v.Source
+			// nested values print below their key, the comment goes there
+			if valueNode.Kind == yaml.ScalarNode {
+				valueNode.LineComment = comment
+			} else {
+				keyNode.LineComment = comment
+			}
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"funcedup/internal/schema/migrations"
+	"funcedup/internal/tenant"
+	"funcedup/pkg/pgconn"
+
+	"github.com/spf13/viper"
+)
+
+// migrate up|down|status: runs versioned migrations without starting the server.
+// With database.tenancy: schema, up also migrates the schema of every tenant.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate needs a subcommand: up, down or status")
+	}
+	subcommand, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("migrate "+subcommand, flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	all, err := pgconn.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	timeout := viper.GetDuration("database.migration_timeout")
+	if timeout <= 0 {
+		timeout = pgconn.DefaultMigrationTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch subcommand {
+	case "up":
+		return runTask(databaseModules(), func(m *pgconn.Module) error {
+			if err := m.MigrateUp(ctx, all, *dryRun); err != nil {
+				return err
+			}
+			if m.Tenancy() != pgconn.TenancySchema || *dryRun {
+				return nil
+			}
+			// every tenant schema holds the application tables as well
+			tenants, err := tenant.ListTenants(m.GetDB())
+			if err != nil {
+				return err
+			}
+			tenantMigrations := pgconn.ExcludeMigrations(all, migrations.Shared)
+			for _, t := range tenants {
+				if t.Schema == "" {
+					continue
+				}
+				if err := m.MigrateSchema(ctx, t.Schema, tenantMigrations); err != nil {
+					return fmt.Errorf("tenant %s: %w", t.Slug, err)
+				}
+			}
+			return nil
+		})
+	case "down":
+		if *steps < 1 {
+			return errors.New("--steps must be at least 1")
+		}
+		return runTask(databaseModules(), func(m *pgconn.Module) error {
+			return m.MigrateDown(ctx, all, *steps, *dryRun)
+		})
+	case "status":
+		return runTask(databaseModules(), func(m *pgconn.Module) error {
+			statuses, err := m.MigrationStatus(ctx, all)
+			if err != nil {
+				return err
+			}
+			printMigrationStatus(statuses)
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", subcommand)
+	}
+}
+
+func printMigrationStatus(statuses []pgconn.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state, appliedAt = "applied", status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	w.Flush()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/pgconn"
+)
+
+// rls print|apply|verify: manages postgres row level security policies of the
+// tenant scoped tables. Apply needs a role that owns the tables.
+func runRLS(args []string) error {
+	if len(args) == 0 {
+		return errors.New("rls needs a subcommand: print, apply or verify")
+	}
+	subcommand, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("rls "+subcommand, flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of running it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch subcommand {
+	case "print":
+		return runTask(databaseModules(), func(m *pgconn.Module) error {
+			statements, err := m.RLSStatements(schema.Models()...)
+			if err != nil {
+				return err
+			}
+			for _, statement := range statements {
+				fmt.Println(statement + ";")
+			}
+			return nil
+		})
+	case "apply":
+		return runTask(databaseModules(), func(m *pgconn.Module) error {
+			return m.ApplyRLS(ctx, *dryRun, schema.Models()...)
+		})
+	case "verify":
+		return runTask(databaseModules(), runRLSVerify)
+	default:
+		return fmt.Errorf("unknown rls subcommand %q", subcommand)
+	}
+}
+
+// runRLSVerify lists every table with its policies and fails if a tenant
+// scoped table is unprotected.
+func runRLSVerify(m *pgconn.Module) error {
+	ctx := context.Background()
+
+	tables, err := m.RLSStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tTENANT SCOPED\tRLS\tFORCED\tPOLICIES\tSTATUS")
+	for _, t := range tables {
+		status := "ok"
+		switch {
+		case t.Missing():
+			status = "MISSING"
+			missing = append(missing, t.Table)
+		case !t.TenantScoped:
+			status = "global"
+		}
+		fmt.Fprintf(w, "%s\t%t\t%t\t%t\t%d\t%s\n", t.Table, t.TenantScoped, t.Enabled, t.Forced, t.Policies, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	bypass, err := m.RoleBypassesRLS(ctx)
+	if err != nil {
+		return err
+	}
+	if bypass {
+		fmt.Println("\nwarning: the configured database user bypasses row level security")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("tenant scoped tables without row level security: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
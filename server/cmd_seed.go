@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"funcedup/internal/seeder"
+	"funcedup/pkg/config"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+)
+
+// seed [--profile p | --fixtures path | --generate [generator flags]] [--reset]:
+// seeds test data without starting the server.
+// Runs regardless of seeder.enabled but still refuses production databases.
+// Generator flags override seeder.generator.*.
+func runSeed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	reset := flags.Bool("reset", false, "truncate seeded tables before seeding")
+	profile := flags.String("profile", "", "seed profile, defaults to seeder.profile")
+	fixtures := flags.String("fixtures", "", "fixture file or directory to load instead of a profile")
+	generate := flags.Bool("generate", false, "generate synthetic data instead of seeding a profile")
+	tenantSlug := flags.String("tenant", "", "tenant to seed, defaults to seeder.tenant")
+
+	var gen seeder.GeneratorConfig
+	flags.Int64Var(&gen.Seed, "seed", 0, "generator random seed, the same seed produces the same data")
+	flags.IntVar(&gen.Users, "users", 0, "generator user count")
+	flags.IntVar(&gen.Tags, "tags", 0, "generator tag count")
+	flags.IntVar(&gen.ContentPerUser, "content-per-user", 0, "generator average posts per user")
+	flags.IntVar(&gen.TagsPerContent, "tags-per-content", 0, "generator maximum tags per post")
+	flags.IntVar(&gen.RepliesPerDiscussion, "replies-per-discussion", 0, "generator average replies per discussion")
+	flags.IntVar(&gen.MaxReplyDepth, "max-reply-depth", 0, "generator maximum reply nesting")
+	flags.IntVar(&gen.NotesPerContent, "notes-per-content", 0, "generator average notes per post")
+	flags.IntVar(&gen.FollowsPerUser, "follows-per-user", 0, "generator average follows per user")
+	flags.IntVar(&gen.BatchSize, "batch-size", 0, "generator insert batch size")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *tenantSlug != "" {
+		config.Set("seeder.tenant", *tenantSlug)
+	}
+
+	options := fx.Options(
+		databaseModules(),
+		seeder.InjectDomain("seeder"),
+	)
+
+	return runTask(options, func(d *seeder.Domain) error {
+		if err := d.GuardProduction(context.Background()); err != nil {
+			return err
+		}
+		if *profile == "" {
+			*profile = viper.GetString("seeder.profile")
+		}
+
+		if *reset {
+			if err := d.Reset(); err != nil {
+				return err
+			}
+		}
+		if *fixtures != "" {
+			return d.SeedFixtures(*fixtures)
+		}
+		if *generate {
+			return d.Generate(generatorOverrides(flags, d.GeneratorConfig(), gen))
+		}
+		return d.SeedProfile(*profile)
+	})
+}
+
+// generatorOverrides copies the generator flags that were set on the command
+// line over the configured values.
+func generatorOverrides(flags *flag.FlagSet, config seeder.GeneratorConfig, set seeder.GeneratorConfig) seeder.GeneratorConfig {
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			config.Seed = set.Seed
+		case "users":
+			config.Users = set.Users
+		case "tags":
+			config.Tags = set.Tags
+		case "content-per-user":
+			config.ContentPerUser = set.ContentPerUser
+		case "tags-per-content":
+			config.TagsPerContent = set.TagsPerContent
+		case "replies-per-discussion":
+			config.RepliesPerDiscussion = set.RepliesPerDiscussion
+		case "max-reply-depth":
+			config.MaxReplyDepth = set.MaxReplyDepth
+		case "notes-per-content":
+			config.NotesPerContent = set.NotesPerContent
+		case "follows-per-user":
+			config.FollowsPerUser = set.FollowsPerUser
+		case "batch-size":
+			config.BatchSize = set.BatchSize
+		}
+	})
+	return config
+}
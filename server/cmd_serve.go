@@ -0,0 +1,70 @@
+package main
+
+import (
+	"funcedup/internal/admin"
+	"funcedup/internal/auth"
+	"funcedup/internal/content"
+	"funcedup/internal/discussion"
+	"funcedup/internal/note"
+	"funcedup/internal/schema"
+	"funcedup/internal/schema/migrations"
+	"funcedup/internal/seeder"
+	"funcedup/internal/tenant"
+	"funcedup/pkg/config"
+	"funcedup/pkg/jwt"
+	"funcedup/pkg/logger"
+	"funcedup/pkg/metrics"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+	"funcedup/pkg/tracing"
+
+	"go.uber.org/fx"
+)
+
+// serve: migrates, seeds and runs the http server until interrupted.
+func runServe(args []string) error {
+	app := fx.New(
+		//* Modules ---------------------------------------------------------------
+		logger.InjectModule("logger"),
+		config.InjectModule("config"),
+		pgconn.InjectModule("database"),
+		server.InjectModule("server"),
+		jwt.InjectModule("jwt"),
+		metrics.InjectModule("metrics"),
+		tracing.InjectModule("tracing"),
+		//* Domains ---------------------------------------------------------------
+		seeder.InjectDomain("seeder"),
+		tenant.InjectDomain("tenant"),
+		auth.InjectDomain("auth"),
+		content.InjectDomain("content"),
+		discussion.InjectDomain("discussion"),
+		note.InjectDomain("note"),
+		admin.InjectDomain("admin"),
+		//* Config ----------------------------------------------------------------
+		// runs after the invokes of the modules above, so every scope is loaded
+		fx.Invoke(config.Check),
+		//* Health checks ---------------------------------------------------------
+		fx.Provide(server.AsHealthCheck(func(m *pgconn.Module) server.HealthCheck {
+			return server.HealthCheck{Name: "database", Check: m.Ping}
+		})),
+		fx.Provide(server.AsHealthCheck(func(m *pgconn.Module) server.HealthCheck {
+			return server.HealthCheck{Name: "migrations", Check: m.MigrationCheck(migrations.FS)}
+		})),
+		//* Migration -------------------------------------------------------------
+		fx.Invoke(func(m *pgconn.Module) error {
+			return m.ApplyMigrations(migrations.FS)
+		}),
+		fx.Invoke(func(m *pgconn.Module) {
+			m.ApplySchema(schema.Models()...)
+		}),
+		//* fx logs ---------------------------------------------------------------
+		fx.NopLogger,
+	)
+	// fx only logs startup errors, fx.NopLogger would swallow them
+	if err := app.Err(); err != nil {
+		return configReport(err)
+	}
+	app.Run()
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"funcedup/internal/schema/migrations"
+	"funcedup/internal/tenant"
+	"funcedup/pkg/pgconn"
+
+	"gorm.io/gorm"
+)
+
+// tenant create|list|disable: manages the tenants of a multi-tenant deployment.
+func runTenant(args []string) error {
+	if len(args) == 0 {
+		return errors.New("tenant needs a subcommand: create, list or disable")
+	}
+	subcommand, args := args[0], args[1:]
+
+	switch subcommand {
+	case "create":
+		return runTenantCreate(args)
+	case "list":
+		return runTenantList(args)
+	case "disable":
+		return runTenantDisable(args)
+	default:
+		return fmt.Errorf("unknown tenant subcommand %q", subcommand)
+	}
+}
+
+func runTenantCreate(args []string) error {
+	flags := flag.NewFlagSet("tenant create", flag.ContinueOnError)
+	name := flags.String("name", "", "display name, defaults to the slug")
+	schemaName := flags.String("schema", "", "postgres schema holding the tenant tables, for database.tenancy: schema")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("tenant create needs exactly one slug")
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		slug := flags.Arg(0)
+		if m.Tenancy() == pgconn.TenancySchema {
+			if *schemaName == "" {
+				return errors.New("--schema is required with database.tenancy: schema")
+			}
+		} else if *schemaName != "" {
+			return errors.New("--schema only applies to database.tenancy: schema")
+		}
+		if err := tenant.ValidateSchema(*schemaName); err != nil {
+			return err
+		}
+
+		// checked first, so an existing tenant never gets a second schema
+		if _, err := tenant.FindTenant(m.GetDB(), slug); err == nil {
+			return fmt.Errorf("%w: %s", tenant.ErrTenantExists, slug)
+		} else if !errors.Is(err, tenant.ErrTenantNotFound) {
+			return err
+		}
+
+		if *schemaName != "" {
+			all, err := pgconn.LoadMigrations(migrations.FS)
+			if err != nil {
+				return err
+			}
+			if err := m.MigrateSchema(context.Background(), *schemaName, pgconn.ExcludeMigrations(all, migrations.Shared)); err != nil {
+				return err
+			}
+		}
+
+		t, err := tenant.CreateTenant(m.GetDB(), slug, *name, *schemaName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created tenant %s (%s)\n", t.Slug, t.ID)
+		if t.Schema != "" {
+			fmt.Printf("schema %s is migrated\n", t.Schema)
+		}
+		return nil
+	})
+}
+
+func runTenantList(args []string) error {
+	if len(args) != 0 {
+		return errors.New("tenant list takes no arguments")
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		tenants, err := tenant.ListTenants(m.GetDB())
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SLUG\tNAME\tSCHEMA\tSTATUS\tID")
+		for _, t := range tenants {
+			status := "active"
+			if t.IsDisabled() {
+				status = "disabled"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Slug, t.Name, t.Schema, status, t.ID)
+		}
+		return w.Flush()
+	})
+}
+
+func runTenantDisable(args []string) error {
+	if len(args) != 1 {
+		return errors.New("tenant disable needs exactly one slug")
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		t, err := tenant.DisableTenant(m.GetDB(), args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("tenant %s (%s) is disabled\n", t.Slug, t.ID)
+		return nil
+	})
+}
+
+// withTenantDB runs fn in a transaction for the tenant with the given slug,
+// so the tenant filter, the search path of schema tenancy and row level
+// security policies apply. slug may only be empty without tenancy.
+func withTenantDB(m *pgconn.Module, slug string, fn func(db *gorm.DB) error) error {
+	ctx := context.Background()
+	if slug == "" {
+		if m.Tenancy() != pgconn.TenancyNone {
+			return fmt.Errorf("--tenant is required with database.tenancy: %s", m.Tenancy())
+		}
+		return m.Transaction(ctx, fn)
+	}
+
+	t, err := tenant.FindTenant(m.GetDB(), slug)
+	if err != nil {
+		return err
+	}
+	return m.Transaction(pgconn.WithTenant(ctx, pgconn.Tenant{ID: t.ID, Slug: t.Slug, Schema: t.Schema}), fn)
+}
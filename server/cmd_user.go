@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"funcedup/internal/auth"
+	"funcedup/internal/schema"
+	"funcedup/pkg/pgconn"
+
+	"gorm.io/gorm"
+)
+
+// user create|promote|disable: manages accounts without going through the API.
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return errors.New("user needs a subcommand: create, promote or disable")
+	}
+	subcommand, args := args[0], args[1:]
+
+	switch subcommand {
+	case "create":
+		return runUserCreate(args)
+	case "promote":
+		return runUserPromote(args)
+	case "disable":
+		return runUserDisable(args)
+	default:
+		return fmt.Errorf("unknown user subcommand %q", subcommand)
+	}
+}
+
+func runUserCreate(args []string) error {
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := flags.String("username", "", "username of the new account")
+	email := flags.String("email", "", "email of the new account")
+	password := flags.String("password", "", "password of the new account")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+	role := flags.String("role", schema.UserRoleMember, "role of the new account")
+	tenantSlug := flags.String("tenant", "", "tenant of the account, required with database.tenancy: row or schema")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" || *email == "" {
+		return errors.New("--username and --email are required")
+	}
+	if err := validateRole(*role); err != nil {
+		return err
+	}
+	if *passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if len(*password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		return withTenantDB(m, *tenantSlug, func(db *gorm.DB) error {
+			user, err := auth.CreateUser(db, *username, *email, *password, *role)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("created user %s (%s) with role %s\n", user.Username, user.ID, user.Role)
+			return nil
+		})
+	})
+}
+
+func runUserPromote(args []string) error {
+	flags := flag.NewFlagSet("user promote", flag.ContinueOnError)
+	role := flags.String("role", schema.UserRoleAdmin, "role to assign")
+	tenantSlug := flags.String("tenant", "", "tenant of the account, required with database.tenancy: row or schema")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("user promote needs exactly one username or email")
+	}
+	if err := validateRole(*role); err != nil {
+		return err
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		return withTenantDB(m, *tenantSlug, func(db *gorm.DB) error {
+			user, err := auth.SetRole(db, flags.Arg(0), *role)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("user %s (%s) now has role %s\n", user.Username, user.ID, *role)
+			return nil
+		})
+	})
+}
+
+func runUserDisable(args []string) error {
+	flags := flag.NewFlagSet("user disable", flag.ContinueOnError)
+	tenantSlug := flags.String("tenant", "", "tenant of the account, required with database.tenancy: row or schema")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("user disable needs exactly one username or email")
+	}
+
+	return runTask(databaseModules(), func(m *pgconn.Module) error {
+		return withTenantDB(m, *tenantSlug, func(db *gorm.DB) error {
+			user, err := auth.DisableUser(db, flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("user %s (%s) is disabled\n", user.Username, user.ID)
+			return nil
+		})
+	})
+}
+
+func validateRole(role string) error {
+	switch role {
+	case schema.UserRoleMember, schema.UserRoleAdmin:
+		return nil
+	default:
+		return fmt.Errorf("unknown role %q, expected %s or %s", role, schema.UserRoleMember, schema.UserRoleAdmin)
+	}
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"funcedup/internal/auth"
+	"funcedup/pkg/config"
+	"funcedup/pkg/logger"
+	"funcedup/pkg/server"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope  string
+	logger *zap.Logger
+	config *Config
+	params Params
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	Levels    *logger.Levels
+	Server    *server.Module
+	Config    *config.Module
+}
+
+type Config struct {
+	LevelTTL    time.Duration `mapstructure:"level_ttl" validate:"gte=0"`
+	MaxLevelTTL time.Duration `mapstructure:"max_level_ttl" validate:"gte=0"`
+}
+
+const (
+	// changed levels revert on their own, a forgotten debug level would
+	// otherwise flood the logs
+	DefaultLevelTTL    = 15 * time.Minute
+	DefaultMaxLevelTTL = 24 * time.Hour
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		LevelTTL:    DefaultLevelTTL,
+		MaxLevelTTL: DefaultMaxLevelTTL,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupRoutes() {
+	g := d.params.Server.APIGroup("/admin", auth.RequireAdmin)
+
+	g.GET("/log-level", d.getLogLevel)
+	g.PUT("/log-level", d.setLogLevel)
+	g.GET("/config", d.getConfig)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting admin domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping admin domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Admin Configuration -----")
+	d.logger.Debug("LevelTTL", zap.Duration("level_ttl", d.config.LevelTTL))
+	d.logger.Debug("MaxLevelTTL", zap.Duration("max_level_ttl", d.config.MaxLevelTTL))
+	d.logger.Debug("-------------------------------")
+}
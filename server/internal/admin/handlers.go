@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"funcedup/pkg/apperr"
+	"funcedup/pkg/config"
+	"funcedup/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// -------------------------------------------------------------------------
+// Requests
+// -------------------------------------------------------------------------
+
+type SetLogLevelRequest struct {
+	Level string `json:"level" validate:"required_without=Reset,omitempty,oneof=debug info warn error"`
+	// Name of a logger as shown in the log, e.g. "[database]" or "database".
+	// Empty changes the global level.
+	Logger string `json:"logger" validate:"max=128"`
+	// Go duration after which the change is reverted, e.g. "10m".
+	// Defaults to admin.level_ttl.
+	TTL string `json:"ttl"`
+	// Removes the override of Logger instead of setting it.
+	Reset bool `json:"reset"`
+}
+
+// -------------------------------------------------------------------------
+// Handlers
+// -------------------------------------------------------------------------
+
+// GET /api/v1/admin/log-level
+func (d *Domain) getLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, d.params.Levels.State())
+}
+
+// PUT /api/v1/admin/log-level
+func (d *Domain) setLogLevel(c echo.Context) error {
+	var req SetLogLevelRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	name := loggerName(req.Logger)
+	if req.Reset {
+		if name == "" {
+			return apperr.BadRequest("reset requires a logger")
+		}
+		d.params.Levels.RemoveOverride(name)
+		server.Logger(c, d.logger).Info("Log level override removed", zap.String("name", name))
+		return c.JSON(http.StatusOK, d.params.Levels.State())
+	}
+
+	level, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		return apperr.BadRequest(err.Error())
+	}
+	ttl, err := d.parseTTL(req.TTL)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		d.params.Levels.SetLevel(level, ttl)
+		server.Logger(c, d.logger).Info("Log level changed", zap.Stringer("level", level), zap.Duration("ttl", ttl))
+	} else {
+		d.params.Levels.SetOverride(name, level, ttl)
+		server.Logger(c, d.logger).Info("Log level override set", zap.String("name", name), zap.Stringer("level", level), zap.Duration("ttl", ttl))
+	}
+
+	return c.JSON(http.StatusOK, d.params.Levels.State())
+}
+
+// GET /api/v1/admin/config
+// The effective config as of the last reload, each value with the layer it
+// comes from, secrets redacted. ?flat=true lists the keys instead of nesting
+// them.
+func (d *Domain) getConfig(c echo.Context) error {
+	settings := d.params.Config.Settings()
+	if c.QueryParam("flat") == "true" {
+		return c.JSON(http.StatusOK, settings)
+	}
+	return c.JSON(http.StatusOK, config.Tree(settings))
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// parseTTL falls back to admin.level_ttl and caps at admin.max_level_ttl.
+func (d *Domain) parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return d.config.LevelTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, apperr.BadRequest(fmt.Sprintf("invalid ttl %q, expected a positive duration, e.g. 10m", value))
+	}
+	if d.config.MaxLevelTTL > 0 && ttl > d.config.MaxLevelTTL {
+		return 0, apperr.BadRequest(fmt.Sprintf("ttl must not exceed %s", d.config.MaxLevelTTL))
+	}
+	return ttl, nil
+}
+
+// loggerName adds the brackets modules and domains name their loggers with,
+// so "database" and "[database]" refer to the same logger.
+func loggerName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.HasPrefix(name, "[") {
+		return name
+	}
+	scope, rest, found := strings.Cut(name, ".")
+	if found {
+		return "[" + scope + "]." + rest
+	}
+	return "[" + name + "]"
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/jwt"
+	"funcedup/pkg/metrics"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope    string
+	logger   *zap.Logger
+	config   *Config
+	params   Params
+	counters counters
+}
+
+type counters struct {
+	signups *prometheus.CounterVec
+	signins *prometheus.CounterVec
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	DB        *pgconn.Module
+	Metrics   *metrics.Module
+	Server    *server.Module
+	JWT       *jwt.Module
+}
+
+type Config struct {
+	CookieSecure bool `mapstructure:"cookie_secure"`
+}
+
+const (
+	DefaultCookieSecure = false
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.setupMetrics()
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		CookieSecure: DefaultCookieSecure,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupMetrics() {
+	d.counters.signups = d.params.Metrics.Counter("auth_signups_total", "Accounts created through signup.")
+	d.counters.signins = d.params.Metrics.Counter("auth_signins_total", "Signin attempts by result.", "result")
+}
+
+func (d *Domain) setupRoutes() {
+	// resolve the current user for every request
+	d.params.Server.GetServer().Use(d.params.JWT.Middleware(), d.resolveUser)
+	// row level security and schema tenancy only apply inside a transaction
+	if d.params.DB.RowLevelSecurity() || d.params.DB.Tenancy() == pgconn.TenancySchema {
+		d.params.Server.GetServer().Use(d.scopeRequest)
+	}
+
+	g := d.params.Server.APIGroup("/auth")
+
+	g.POST("/signup", d.signup)
+	g.POST("/signin", d.signin)
+	g.POST("/signout", d.signout)
+	g.GET("/me", d.me, server.RequireAuth)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting auth domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping auth domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Auth Configuration -----")
+	d.logger.Debug("CookieSecure", zap.Bool("cookie_secure", d.config.CookieSecure))
+	d.logger.Debug("-------------------------------")
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/jwt"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// dummyHash is compared against when a user is not found so that signin takes
+// roughly the same time whether or not the account exists.
+const dummyHash = "$2a$10$lpdlU9kE77o0A8NbHCkJz.Mz0wuJSU9QHJ7ThjlCrl.VyOtPM2Zr2"
+
+// -------------------------------------------------------------------------
+// Requests
+// -------------------------------------------------------------------------
+
+type SignupRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=32,alphanum"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type SigninRequest struct {
+	// Identifier is either the email or the username of the account.
+	Identifier string `json:"identifier" validate:"required,max=255"`
+	Password   string `json:"password" validate:"required,max=72"`
+}
+
+// -------------------------------------------------------------------------
+// Responses
+// -------------------------------------------------------------------------
+
+type SessionResponse struct {
+	User      *schema.User `json:"user"`
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+}
+
+// -------------------------------------------------------------------------
+// Handlers
+// -------------------------------------------------------------------------
+
+// POST /api/v1/auth/signup
+func (d *Domain) signup(c echo.Context) error {
+	var req SignupRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := d.CreateUser(c.Request().Context(), req.Username, req.Email, req.Password)
+	if errors.Is(err, ErrEmailTaken) || errors.Is(err, ErrUsernameTaken) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	d.counters.signups.WithLabelValues().Inc()
+
+	return d.startSession(c, http.StatusCreated, user)
+}
+
+// POST /api/v1/auth/signin
+func (d *Domain) signin(c echo.Context) error {
+	var req SigninRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	user, err := d.Authenticate(c.Request().Context(), req.Identifier, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		d.counters.signins.WithLabelValues("invalid_credentials").Inc()
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if errors.Is(err, ErrAccountDisabled) {
+		d.counters.signins.WithLabelValues("disabled").Inc()
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to authenticate user: %w", err)
+	}
+	d.counters.signins.WithLabelValues("success").Inc()
+
+	return d.startSession(c, http.StatusOK, user)
+}
+
+// POST /api/v1/auth/signout
+func (d *Domain) signout(c echo.Context) error {
+	d.params.JWT.ClearCookie(c, d.config.CookieSecure)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GET /api/v1/auth/me
+func (d *Domain) me(c echo.Context) error {
+	user, ok := GetUser(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// startSession issues an auth token for the user, sets it as a cookie and
+// returns it in the response body for non-browser clients.
+func (d *Domain) startSession(c echo.Context, status int, user *schema.User) error {
+	token, expiresAt, err := d.params.JWT.Sign(jwt.ScopeAuth, user.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	d.params.JWT.SetCookie(c, token, expiresAt, d.config.CookieSecure)
+
+	return c.JSON(status, SessionResponse{
+		User:      user,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+func findUserByIdentifier(tx *gorm.DB, identifier string) (*schema.User, error) {
+	var user schema.User
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+
+	err := tx.Where("email = ? OR username = ?", identifier, identifier).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/jwt"
+	"funcedup/pkg/logger"
+	"funcedup/pkg/server"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// resolveUser loads the user referenced by the verified token claims and
+// stores it on the echo context. Unknown users continue anonymously.
+func (d *Domain) resolveUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, ok := jwt.GetClaims(c)
+		if !ok {
+			return next(c)
+		}
+
+		id, err := uuid.Parse(claims.Subject)
+		if err != nil {
+			server.Logger(c, d.logger).Debug("Ignoring token with invalid subject", zap.String("subject", claims.Subject))
+			return next(c)
+		}
+
+		// a transaction of its own so row level security policies see the tenant
+		var user schema.User
+		err = d.params.DB.Transaction(c.Request().Context(), func(tx *gorm.DB) error {
+			return tx.First(&user, "id = ?", id).Error
+		})
+		if isNotFound(err) {
+			return next(c)
+		}
+		if err != nil {
+			server.Logger(c, d.logger).Error("Error resolving user", zap.Error(err))
+			return next(c)
+		}
+		if user.IsDisabled() {
+			return next(c)
+		}
+
+		c.Set(server.ContextKeyUser, &user)
+		c.Set(server.ContextKeyUserID, user.ID)
+		ctx := logger.WithFields(c.Request().Context(), zap.String("user_id", user.ID.String()))
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// scopeRequest runs the rest of an API request in a single transaction
+// carrying the tenant, so postgres row level security policies and the search
+// path of schema tenancy apply to every query of the handler. The transaction
+// rolls back when the handler returns an error. The response is held back
+// until the commit, a failed commit is answered by the error handler instead.
+// Health checks and metrics scrapes outside of APIPrefix are not scoped.
+func (d *Domain) scopeRequest(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !strings.HasPrefix(c.Request().URL.Path, server.APIPrefix) {
+			return next(c)
+		}
+
+		res := c.Response()
+		writer := res.Writer
+		buffer := &bufferedWriter{header: writer.Header().Clone(), status: http.StatusOK}
+		res.Writer = buffer
+
+		err := d.params.DB.WithTransaction(c.Request().Context(), func(ctx context.Context) error {
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		})
+		res.Writer = writer
+		if err != nil {
+			// drop what the handler wrote, headers included
+			res.Committed, res.Status, res.Size = false, http.StatusOK, 0
+			return err
+		}
+		return buffer.flush(writer)
+	}
+}
+
+// bufferedWriter keeps a response in memory, see scopeRequest.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// flush sends the buffered response to the client.
+func (w *bufferedWriter) flush(to http.ResponseWriter) error {
+	header := to.Header()
+	for key := range header {
+		delete(header, key)
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+	to.WriteHeader(w.status)
+	_, err := to.Write(w.body.Bytes())
+	return err
+}
+
+// RequireAdmin rejects anonymous requests with 401 and users without the
+// admin role with 403.
+// e.g. g := d.params.Server.APIGroup("/admin", auth.RequireAdmin)
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := GetUser(c)
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		}
+		if !user.IsAdmin() {
+			return echo.NewHTTPError(http.StatusForbidden)
+		}
+		return next(c)
+	}
+}
+
+// GetUser returns the authenticated user stored on the echo context.
+func GetUser(c echo.Context) (*schema.User, bool) {
+	user, ok := c.Get(server.ContextKeyUser).(*schema.User)
+	return user, ok
+}
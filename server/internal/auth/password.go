@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultBcryptCost = bcrypt.DefaultCost
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// HashPassword hashes a plain text password with bcrypt.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), DefaultBcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares a bcrypt hash with a plain text password.
+// Returns ErrInvalidCredentials when they do not match.
+func CheckPassword(hash string, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrHashTooShort) {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// IsHashed reports whether the value looks like a bcrypt hash.
+// Used to detect rows that still hold a plain text password, e.g. old seed data.
+func IsHashed(value string) bool {
+	if !strings.HasPrefix(value, "$2") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
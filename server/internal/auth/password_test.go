@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHashed(hash) {
+		t.Errorf("IsHashed(%q) = false", hash)
+	}
+	if err := CheckPassword(hash, "correct horse"); err != nil {
+		t.Errorf("matching password: %v", err)
+	}
+	if err := CheckPassword(hash, "battery staple"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+	// plain text left over in old seed data must not sign anyone in
+	if err := CheckPassword("correct horse", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("plain text hash: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestIsHashed(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{dummyHash, true},
+		{"", false},
+		{"password123", false},
+		{"$2a$", false},
+		{"$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA", false},
+	}
+	for _, test := range tests {
+		if got := IsHashed(test.value); got != test.want {
+			t.Errorf("IsHashed(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+// The dummy hash only evens out signin timings when comparing against it
+// costs as much as comparing against a real hash.
+func TestDummyHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != DefaultBcryptCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, DefaultBcryptCost)
+	}
+	if err := CheckPassword(dummyHash, "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v, want ErrInvalidCredentials", err)
+	}
+}
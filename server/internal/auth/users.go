@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"funcedup/internal/schema"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailTaken      = errors.New("email is already registered")
+	ErrUsernameTaken   = errors.New("username is already taken")
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrUserNotFound    = errors.New("user not found")
+)
+
+// CreateUser registers a new user with a hashed password.
+// Returns ErrEmailTaken or ErrUsernameTaken when the account would not be unique.
+func (d *Domain) CreateUser(ctx context.Context, username string, email string, password string) (*schema.User, error) {
+	return CreateUser(d.params.DB.Scoped(ctx), username, email, password, schema.UserRoleMember)
+}
+
+// Authenticate looks up a user by email or username and verifies the password.
+// Returns ErrInvalidCredentials if either is wrong.
+func (d *Domain) Authenticate(ctx context.Context, identifier string, password string) (*schema.User, error) {
+	return Authenticate(d.params.DB.Scoped(ctx), identifier, password)
+}
+
+// CreateUser registers a new user with the given role.
+// Usable outside of the fx app, e.g. from the command line.
+func CreateUser(db *gorm.DB, username string, email string, password string, role string) (*schema.User, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := schema.User{
+		Username:     normalizeUsername(username),
+		Email:        normalizeEmail(email),
+		PasswordHash: hash,
+		Role:         role,
+	}
+
+	if err := checkUnique(db, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+
+	// a savepoint when db is a request transaction, the failed insert would
+	// abort it and the check below with it
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&user).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// lost a race against a concurrent signup, report which field collided
+		if err := checkUnique(db, user.Username, user.Email); err != nil {
+			return nil, err
+		}
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Authenticate looks up a user by email or username and verifies the password.
+// Returns ErrInvalidCredentials if either is wrong and ErrAccountDisabled for
+// disabled accounts.
+func Authenticate(db *gorm.DB, identifier string, password string) (*schema.User, error) {
+	user, err := FindUser(db, identifier)
+	if errors.Is(err, ErrUserNotFound) {
+		_ = CheckPassword(dummyHash, password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckPassword(user.PasswordHash, password); err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	return user, nil
+}
+
+// FindUser looks up a user by email or username.
+func FindUser(db *gorm.DB, identifier string) (*schema.User, error) {
+	user, err := findUserByIdentifier(db, identifier)
+	if isNotFound(err) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// SetRole changes the role of the user identified by email or username.
+func SetRole(db *gorm.DB, identifier string, role string) (*schema.User, error) {
+	user, err := FindUser(db, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(user).Update("role", role).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DisableUser prevents the user identified by email or username from signing
+// in. Existing tokens stop resolving to the user as well.
+func DisableUser(db *gorm.DB, identifier string) (*schema.User, error) {
+	user, err := FindUser(db, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDisabled() {
+		return user, nil
+	}
+
+	if err := db.Model(user).Update("disabled_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func checkUnique(db *gorm.DB, username string, email string) error {
+	var count int64
+
+	if err := db.Model(&schema.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+
+	if err := db.Model(&schema.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"funcedup/internal/schema"
+	"funcedup/internal/schema/schematest"
+)
+
+func TestCreateUser(t *testing.T) {
+	db := schematest.Open(t)
+
+	user, err := CreateUser(db, " Alice ", "Alice@Example.com", "password123", schema.UserRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alice" || user.Email != "alice@example.com" {
+		t.Errorf("got %q %q, want the normalized username and email", user.Username, user.Email)
+	}
+	if user.PasswordHash == "password123" || !IsHashed(user.PasswordHash) {
+		t.Errorf("password is not hashed: %q", user.PasswordHash)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		want     error
+	}{
+		{"email", "bob", "ALICE@example.com", ErrEmailTaken},
+		{"username", "ALICE", "bob@example.com", ErrUsernameTaken},
+	}
+	for _, test := range tests {
+		if _, err := CreateUser(db, test.username, test.email, "password123", schema.UserRoleMember); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	db := schematest.Open(t)
+
+	user, err := CreateUser(db, "alice", "alice@example.com", "password123", schema.UserRoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateUser(db, "bob", "bob@example.com", "password123", schema.UserRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DisableUser(db, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, identifier := range []string{"alice", " ALICE@example.com "} {
+		got, err := Authenticate(db, identifier, "password123")
+		if err != nil {
+			t.Errorf("%q: %v", identifier, err)
+			continue
+		}
+		if got.ID != user.ID {
+			t.Errorf("%q: signed in as %s, want %s", identifier, got.ID, user.ID)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		identifier string
+		password   string
+		want       error
+	}{
+		{"wrong password", "alice", "password124", ErrInvalidCredentials},
+		{"unknown user", "carol", "password123", ErrInvalidCredentials},
+		{"disabled", "bob", "password123", ErrAccountDisabled},
+		// the password is checked first, a disabled account does not reveal itself
+		{"disabled wrong password", "bob", "password124", ErrInvalidCredentials},
+	}
+	for _, test := range tests {
+		if _, err := Authenticate(db, test.identifier, test.password); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
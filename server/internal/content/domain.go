@@ -0,0 +1,124 @@
+package content
+
+import (
+	"context"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/metrics"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope    string
+	logger   *zap.Logger
+	config   *Config
+	params   Params
+	counters counters
+}
+
+type counters struct {
+	created *prometheus.CounterVec
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	DB        *pgconn.Module
+	Metrics   *metrics.Module
+	Server    *server.Module
+}
+
+type Config struct {
+	DefaultPageSize int `mapstructure:"default_page_size" validate:"min=1,ltefield=MaxPageSize"`
+	MaxPageSize     int `mapstructure:"max_page_size" validate:"min=1"`
+}
+
+const (
+	DefaultPageSize    = 20
+	DefaultMaxPageSize = 100
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.setupMetrics()
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		DefaultPageSize: DefaultPageSize,
+		MaxPageSize:     DefaultMaxPageSize,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupMetrics() {
+	d.counters.created = d.params.Metrics.Counter("content_created_total", "Content posts created.")
+}
+
+func (d *Domain) setupRoutes() {
+	g := d.params.Server.APIGroup("/content")
+
+	g.GET("", d.listContent)
+	g.GET("/:id", d.getContent)
+	g.POST("", d.createContent, server.RequireAuth)
+	g.PUT("/:id", d.updateContent, server.RequireAuth)
+	g.DELETE("/:id", d.deleteContent, server.RequireAuth)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting content domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping content domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Content Configuration -----")
+	d.logger.Debug("DefaultPageSize", zap.Int("default_page_size", d.config.DefaultPageSize))
+	d.logger.Debug("MaxPageSize", zap.Int("max_page_size", d.config.MaxPageSize))
+	d.logger.Debug("-------------------------------")
+}
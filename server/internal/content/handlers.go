@@ -0,0 +1,324 @@
+package content
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/server"
+	"funcedup/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// -------------------------------------------------------------------------
+// Requests
+// -------------------------------------------------------------------------
+
+type ListContentRequest struct {
+	util.Pagination
+	OwnerID string `query:"owner_id" validate:"omitempty,uuid"`
+	Tag     string `query:"tag" validate:"omitempty,max=64"`
+}
+
+type CreateContentRequest struct {
+	Title string   `json:"title" validate:"required,max=255"`
+	Body  string   `json:"body" validate:"required"`
+	Tags  []string `json:"tags" validate:"max=16,dive,required,max=64"`
+}
+
+type UpdateContentRequest struct {
+	Title string   `json:"title" validate:"required,max=255"`
+	Body  string   `json:"body" validate:"required"`
+	Tags  []string `json:"tags" validate:"max=16,dive,required,max=64"`
+}
+
+// -------------------------------------------------------------------------
+// Handlers
+// -------------------------------------------------------------------------
+
+// GET /api/v1/content
+func (d *Domain) listContent(c echo.Context) error {
+	var req ListContentRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+	page := req.Pagination.Normalize(d.config.DefaultPageSize, d.config.MaxPageSize)
+
+	query := d.params.DB.Scoped(c.Request().Context()).Model(&schema.Content{}).Scopes(hideReplies)
+	if req.OwnerID != "" {
+		query = query.Where("contents.owner_id = ?", req.OwnerID)
+	}
+	if req.Tag != "" {
+		query = query.
+			Joins("JOIN content_tags ON content_tags.content_id = contents.id").
+			Joins("JOIN tags ON tags.id = content_tags.tag_id").
+			Where("tags.name = ?", normalizeTag(req.Tag))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fmt.Errorf("failed to count content: %w", err)
+	}
+
+	var contents []schema.Content
+	err := query.
+		Preload("Tags").
+		Order("contents.created_at DESC").
+		Scopes(page.Scope()).
+		Find(&contents).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to list content: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, util.NewPage(contents, page, total))
+}
+
+// GET /api/v1/content/:id
+func (d *Domain) getContent(c echo.Context) error {
+	content, err := d.findContent(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, content)
+}
+
+// POST /api/v1/content
+func (d *Domain) createContent(c echo.Context) error {
+	userID, ok := server.GetUserID(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	var req CreateContentRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	content := schema.Content{
+		Title:   req.Title,
+		Body:    req.Body,
+		OwnerID: userID,
+	}
+
+	err := d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&content).Error; err != nil {
+			return err
+		}
+		return replaceTags(tx, content.ID, req.Tags)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create content: %w", err)
+	}
+	d.counters.created.WithLabelValues().Inc()
+
+	return d.respondWithContent(c, http.StatusCreated, content.ID)
+}
+
+// PUT /api/v1/content/:id
+func (d *Domain) updateContent(c echo.Context) error {
+	content, err := d.findOwnedContent(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateContentRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(content).
+			Updates(map[string]interface{}{"title": req.Title, "body": req.Body}).
+			Error
+		if err != nil {
+			return err
+		}
+		return replaceTags(tx, content.ID, req.Tags)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update content: %w", err)
+	}
+
+	return d.respondWithContent(c, http.StatusOK, content.ID)
+}
+
+// DELETE /api/v1/content/:id
+// Discussions and notes of the content are deleted with it.
+func (d *Domain) deleteContent(c echo.Context) error {
+	content, err := d.findOwnedContent(c)
+	if err != nil {
+		return err
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		if err := deleteDiscussions(tx, content.ID); err != nil {
+			return err
+		}
+		if err := deleteNotes(tx, content.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("content_id = ?", content.ID).Delete(&schema.ContentTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(content).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete content: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// findContent loads the content referenced by the :id path parameter.
+func (d *Domain) findContent(c echo.Context) (*schema.Content, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid content id")
+	}
+
+	var content schema.Content
+	err = d.params.DB.Scoped(c.Request().Context()).
+		Scopes(hideReplies).
+		Preload("Tags").
+		First(&content, "contents.id = ?", id).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch content: %w", err)
+	}
+
+	return &content, nil
+}
+
+// findOwnedContent loads the content referenced by the :id path parameter and
+// checks that it belongs to the authenticated user.
+func (d *Domain) findOwnedContent(c echo.Context) (*schema.Content, error) {
+	userID, ok := server.GetUserID(c)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	content, err := d.findContent(c)
+	if err != nil {
+		return nil, err
+	}
+	if content.OwnerID != userID {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "content is owned by another user")
+	}
+
+	return content, nil
+}
+
+func (d *Domain) respondWithContent(c echo.Context, status int, id uuid.UUID) error {
+	var content schema.Content
+	err := d.params.DB.Scoped(c.Request().Context()).
+		Preload("Tags").
+		First(&content, "id = ?", id).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to reload content: %w", err)
+	}
+
+	return c.JSON(status, content)
+}
+
+// replaceTags swaps the tags attached to a content for the given tag names,
+// creating tags that do not exist yet.
+func replaceTags(tx *gorm.DB, contentID uuid.UUID, names []string) error {
+	if err := tx.Where("content_id = ?", contentID).Delete(&schema.ContentTag{}).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = normalizeTag(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		// the unique index on the name settles concurrent creates, the request
+		// that lost loads the tag of the winner
+		tag := schema.Tag{Name: name}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Where("name = ?", name).First(&tag).Error; err != nil {
+				return err
+			}
+		}
+
+		contentTag := schema.ContentTag{ContentID: contentID, TagID: tag.ID}
+		if err := tx.Create(&contentTag).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replyTables store the body of their rows as content. That content belongs
+// to the domain of the table, e.g. replies are tombstoned by the discussion
+// domain, and is hidden from the content API.
+var replyTables = []string{"discusion_replies", "note_replies"}
+
+// hideReplies excludes the content of replies, see replyTables.
+func hideReplies(db *gorm.DB) *gorm.DB {
+	for _, table := range replyTables {
+		db = db.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s.content_id = contents.id)", table, table))
+	}
+	return db
+}
+
+// deleteDiscussions removes the discussions of a content together with their
+// replies and the content of those replies.
+func deleteDiscussions(tx *gorm.DB, contentID uuid.UUID) error {
+	discussions := tx.Model(&schema.Discussion{}).Select("id").Where("content_id = ?", contentID)
+	replyContent := tx.Model(&schema.DiscusionReply{}).Select("content_id").Where("discussion_id IN (?)", discussions)
+	if err := tx.Where("id IN (?)", replyContent).Delete(&schema.Content{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("discussion_id IN (?)", discussions).Delete(&schema.DiscusionReply{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("content_id = ?", contentID).Delete(&schema.Discussion{}).Error
+}
+
+// deleteNotes removes the notes on a content together with their replies and
+// the content of those replies.
+func deleteNotes(tx *gorm.DB, contentID uuid.UUID) error {
+	notes := tx.Model(&schema.Note{}).Select("id").Where("content_id = ?", contentID)
+	replyContent := tx.Model(&schema.NoteReply{}).Select("content_id").Where("note_id IN (?)", notes)
+	if err := tx.Where("id IN (?)", replyContent).Delete(&schema.Content{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("note_id IN (?)", notes).Delete(&schema.NoteReply{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("content_id = ?", contentID).Delete(&schema.Note{}).Error
+}
+
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
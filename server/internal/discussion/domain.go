@@ -0,0 +1,132 @@
+package discussion
+
+import (
+	"context"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/metrics"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope    string
+	logger   *zap.Logger
+	config   *Config
+	params   Params
+	counters counters
+}
+
+type counters struct {
+	opened  *prometheus.CounterVec
+	replies *prometheus.CounterVec
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	DB        *pgconn.Module
+	Metrics   *metrics.Module
+	Server    *server.Module
+}
+
+type Config struct {
+	DefaultPageSize int `mapstructure:"default_page_size" validate:"min=1,ltefield=MaxPageSize"`
+	MaxPageSize     int `mapstructure:"max_page_size" validate:"min=1"`
+	MaxReplyDepth   int `mapstructure:"max_reply_depth" validate:"min=1"`
+}
+
+const (
+	DefaultPageSize      = 20
+	DefaultMaxPageSize   = 100
+	DefaultMaxReplyDepth = 8
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.setupMetrics()
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		DefaultPageSize: DefaultPageSize,
+		MaxPageSize:     DefaultMaxPageSize,
+		MaxReplyDepth:   DefaultMaxReplyDepth,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupMetrics() {
+	d.counters.opened = d.params.Metrics.Counter("discussions_opened_total", "Discussions opened on content.")
+	d.counters.replies = d.params.Metrics.Counter("discussion_replies_created_total", "Replies posted to discussions.")
+}
+
+func (d *Domain) setupRoutes() {
+	content := d.params.Server.APIGroup("/content")
+	content.GET("/:id/discussions", d.listDiscussions)
+	content.POST("/:id/discussions", d.openDiscussion, server.RequireAuth)
+
+	g := d.params.Server.APIGroup("/discussions")
+	g.GET("/:id", d.getDiscussion)
+	g.POST("/:id/replies", d.createReply, server.RequireAuth)
+	g.PUT("/:id/replies/:replyId", d.updateReply, server.RequireAuth)
+	g.DELETE("/:id/replies/:replyId", d.deleteReply, server.RequireAuth)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting discussion domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping discussion domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Discussion Configuration -----")
+	d.logger.Debug("DefaultPageSize", zap.Int("default_page_size", d.config.DefaultPageSize))
+	d.logger.Debug("MaxPageSize", zap.Int("max_page_size", d.config.MaxPageSize))
+	d.logger.Debug("MaxReplyDepth", zap.Int("max_reply_depth", d.config.MaxReplyDepth))
+	d.logger.Debug("-------------------------------")
+}
@@ -0,0 +1,395 @@
+package discussion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/server"
+	"funcedup/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// body kept on replies that were deleted while still having replies of their own
+const deletedReplyBody = "[deleted]"
+
+// -------------------------------------------------------------------------
+// Requests
+// -------------------------------------------------------------------------
+
+type OpenDiscussionRequest struct {
+	// optional first reply of the discussion
+	Title string `json:"title" validate:"max=255"`
+	Body  string `json:"body"`
+}
+
+type CreateReplyRequest struct {
+	Title    string `json:"title" validate:"max=255"`
+	Body     string `json:"body" validate:"required"`
+	ParentID string `json:"parentId" validate:"omitempty,uuid"`
+}
+
+type UpdateReplyRequest struct {
+	Title string `json:"title" validate:"max=255"`
+	Body  string `json:"body" validate:"required"`
+}
+
+// -------------------------------------------------------------------------
+// Handlers
+// -------------------------------------------------------------------------
+
+// GET /api/v1/content/:id/discussions
+func (d *Domain) listDiscussions(c echo.Context) error {
+	contentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid content id")
+	}
+
+	var pagination util.Pagination
+	if err := c.Bind(&pagination); err != nil {
+		return err
+	}
+	page := pagination.Normalize(d.config.DefaultPageSize, d.config.MaxPageSize)
+
+	db := d.params.DB.Scoped(c.Request().Context())
+	query := db.Model(&schema.Discussion{}).Where("discussions.content_id = ?", contentID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fmt.Errorf("failed to count discussions: %w", err)
+	}
+
+	replyCount := db.Model(&schema.DiscusionReply{}).
+		Select("count(*)").
+		Where("discusion_replies.discussion_id = discussions.id")
+
+	var discussions []DiscussionSummary
+	err = query.
+		Select("discussions.*, (?) AS reply_count", replyCount).
+		Order("discussions.created_at DESC").
+		Scopes(page.Scope()).
+		Scan(&discussions).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to list discussions: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, util.NewPage(discussions, page, total))
+}
+
+// POST /api/v1/content/:id/discussions
+func (d *Domain) openDiscussion(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	contentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid content id")
+	}
+
+	var req OpenDiscussionRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+	err = db.Select("id").First(&schema.Content{}, "id = ?", contentID).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch content: %w", err)
+	}
+
+	discussion := schema.Discussion{
+		OwnerID:   userID,
+		ContentID: contentID,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&discussion).Error; err != nil {
+			return err
+		}
+		if req.Body == "" {
+			return nil
+		}
+		_, err := createReply(tx, discussion.ID, userID, nil, req.Title, req.Body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open discussion: %w", err)
+	}
+	d.counters.opened.WithLabelValues().Inc()
+
+	return d.respondWithTree(c, http.StatusCreated, discussion.ID)
+}
+
+// GET /api/v1/discussions/:id
+func (d *Domain) getDiscussion(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid discussion id")
+	}
+
+	return d.respondWithTree(c, http.StatusOK, id)
+}
+
+// POST /api/v1/discussions/:id/replies
+func (d *Domain) createReply(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	discussion, err := d.findDiscussion(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateReplyRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	var parentID *uuid.UUID
+	if req.ParentID != "" {
+		id := uuid.MustParse(req.ParentID)
+		depth, err := d.replyDepth(db, discussion.ID, id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest, "parent reply not found in discussion")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch parent reply: %w", err)
+		}
+		if depth >= d.config.MaxReplyDepth {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "maximum reply depth reached")
+		}
+		parentID = &id
+	}
+
+	var node *ReplyNode
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		node, err = createReply(tx, discussion.ID, userID, parentID, req.Title, req.Body)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reply: %w", err)
+	}
+	d.counters.replies.WithLabelValues().Inc()
+
+	return c.JSON(http.StatusCreated, node)
+}
+
+// PUT /api/v1/discussions/:id/replies/:replyId
+func (d *Domain) updateReply(c echo.Context) error {
+	reply, err := d.findOwnedReply(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateReplyRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+	err = db.Model(&schema.Content{}).
+		Where("id = ?", reply.ContentID).
+		Updates(map[string]interface{}{"title": req.Title, "body": req.Body}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to update reply: %w", err)
+	}
+
+	var content schema.Content
+	if err := db.First(&content, "id = ?", reply.ContentID).Error; err != nil {
+		return fmt.Errorf("failed to reload reply content: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, &ReplyNode{
+		DiscusionReply: *reply,
+		Content:        &content,
+		Replies:        []*ReplyNode{},
+	})
+}
+
+// DELETE /api/v1/discussions/:id/replies/:replyId
+// Replies that have replies of their own keep their place in the thread and
+// only lose their content.
+func (d *Domain) deleteReply(c echo.Context) error {
+	reply, err := d.findOwnedReply(c)
+	if err != nil {
+		return err
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		return deleteReply(tx, reply)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete reply: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// createReply stores the reply content and the reply itself.
+func createReply(tx *gorm.DB, discussionID uuid.UUID, ownerID uuid.UUID, parentID *uuid.UUID, title string, body string) (*ReplyNode, error) {
+	content := schema.Content{
+		Title:   title,
+		Body:    body,
+		OwnerID: ownerID,
+	}
+	if err := tx.Create(&content).Error; err != nil {
+		return nil, err
+	}
+
+	reply := schema.DiscusionReply{
+		OwnerID:      ownerID,
+		DiscussionID: discussionID,
+		ContentID:    content.ID,
+		ParentID:     parentID,
+	}
+	if err := tx.Create(&reply).Error; err != nil {
+		return nil, err
+	}
+
+	return &ReplyNode{
+		DiscusionReply: reply,
+		Content:        &content,
+		Replies:        []*ReplyNode{},
+	}, nil
+}
+
+// deleteReply deletes the reply and its content, or only clears the content
+// when the reply has replies of its own.
+func deleteReply(tx *gorm.DB, reply *schema.DiscusionReply) error {
+	var children int64
+	if err := tx.Model(&schema.DiscusionReply{}).Where("parent_id = ?", reply.ID).Count(&children).Error; err != nil {
+		return err
+	}
+
+	if children > 0 {
+		return tx.Model(&schema.Content{}).
+			Where("id = ?", reply.ContentID).
+			Updates(map[string]interface{}{"title": "", "body": deletedReplyBody}).
+			Error
+	}
+
+	if err := tx.Delete(reply).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&schema.Content{}, "id = ?", reply.ContentID).Error
+}
+
+// replyDepth returns how deep the reply sits in the discussion, 1 being a top
+// level reply. Returns gorm.ErrRecordNotFound if the reply is not part of the
+// discussion.
+func (d *Domain) replyDepth(db *gorm.DB, discussionID uuid.UUID, replyID uuid.UUID) (int, error) {
+	depth := 0
+	current := &replyID
+	for current != nil && depth <= d.config.MaxReplyDepth {
+		var reply schema.DiscusionReply
+		err := db.Select("id", "parent_id").
+			First(&reply, "id = ? AND discussion_id = ?", *current, discussionID).
+			Error
+		if err != nil {
+			return 0, err
+		}
+		depth++
+		current = reply.ParentID
+	}
+	return depth, nil
+}
+
+func (d *Domain) findDiscussion(c echo.Context) (*schema.Discussion, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid discussion id")
+	}
+
+	var discussion schema.Discussion
+	err = d.params.DB.Scoped(c.Request().Context()).First(&discussion, "id = ?", id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discussion: %w", err)
+	}
+
+	return &discussion, nil
+}
+
+// findOwnedReply loads the reply referenced by the :id and :replyId path
+// parameters and checks that it belongs to the authenticated user.
+func (d *Domain) findOwnedReply(c echo.Context) (*schema.DiscusionReply, error) {
+	userID, ok := server.GetUserID(c)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	discussionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid discussion id")
+	}
+	replyID, err := uuid.Parse(c.Param("replyId"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid reply id")
+	}
+
+	var reply schema.DiscusionReply
+	err = d.params.DB.Scoped(c.Request().Context()).
+		First(&reply, "id = ? AND discussion_id = ?", replyID, discussionID).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reply: %w", err)
+	}
+	if reply.OwnerID != userID {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "reply is owned by another user")
+	}
+
+	return &reply, nil
+}
+
+// respondWithTree loads a discussion with all of its replies and their content.
+func (d *Domain) respondWithTree(c echo.Context, status int, id uuid.UUID) error {
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	var discussion schema.Discussion
+	err := db.First(&discussion, "id = ?", id).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch discussion: %w", err)
+	}
+
+	var replies []schema.DiscusionReply
+	err = db.Where("discussion_id = ?", id).Order("created_at ASC").Find(&replies).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch replies: %w", err)
+	}
+
+	contentIDs := make([]uuid.UUID, 0, len(replies))
+	for _, reply := range replies {
+		contentIDs = append(contentIDs, reply.ContentID)
+	}
+
+	contents := make(map[uuid.UUID]*schema.Content, len(contentIDs))
+	if len(contentIDs) > 0 {
+		var rows []schema.Content
+		if err := db.Where("id IN ?", contentIDs).Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to fetch reply content: %w", err)
+		}
+		for i := range rows {
+			contents[rows[i].ID] = &rows[i]
+		}
+	}
+
+	return c.JSON(status, buildTree(discussion, replies, contents))
+}
@@ -0,0 +1,99 @@
+package discussion
+
+import (
+	"errors"
+	"testing"
+
+	"funcedup/internal/schema"
+	"funcedup/internal/schema/schematest"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// testThread stores a discussion with a chain of replies, each replying to
+// the one before.
+func testThread(t *testing.T, db *gorm.DB, length int) (schema.Discussion, []schema.DiscusionReply) {
+	t.Helper()
+
+	owner := uuid.New()
+	discussion := schema.Discussion{OwnerID: owner, ContentID: uuid.New()}
+	if err := db.Create(&discussion).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var replies []schema.DiscusionReply
+	var parent *uuid.UUID
+	for i := 0; i < length; i++ {
+		node, err := createReply(db, discussion.ID, owner, parent, "", "reply")
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, node.DiscusionReply)
+		parent = &node.ID
+	}
+	return discussion, replies
+}
+
+func TestReplyDepth(t *testing.T) {
+	db := schematest.Open(t)
+	d := &Domain{config: &Config{MaxReplyDepth: 3}}
+	discussion, replies := testThread(t, db, 5)
+
+	tests := []struct {
+		reply int
+		depth int
+	}{
+		{0, 1},
+		{1, 2},
+		{2, 3},
+		// the walk stops once past the limit
+		{4, 4},
+	}
+	for _, test := range tests {
+		depth, err := d.replyDepth(db, discussion.ID, replies[test.reply].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth != test.depth {
+			t.Errorf("reply %d is at depth %d, want %d", test.reply, depth, test.depth)
+		}
+	}
+
+	_, err := d.replyDepth(db, uuid.New(), replies[0].ID)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("reply of another discussion returned %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestDeleteReply(t *testing.T) {
+	db := schematest.Open(t)
+	_, replies := testThread(t, db, 2)
+	parent, child := replies[0], replies[1]
+
+	// a reply with replies becomes a tombstone
+	if err := deleteReply(db, &parent); err != nil {
+		t.Fatal(err)
+	}
+	var content schema.Content
+	if err := db.First(&content, "id = ?", parent.ContentID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if content.Body != deletedReplyBody {
+		t.Errorf("tombstone body is %q, want %q", content.Body, deletedReplyBody)
+	}
+	if err := db.First(&schema.DiscusionReply{}, "id = ?", parent.ID).Error; err != nil {
+		t.Errorf("tombstone reply is gone: %v", err)
+	}
+
+	// a leaf goes away with its content
+	if err := deleteReply(db, &child); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&schema.DiscusionReply{}, "id = ?", child.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("deleted reply is still there: %v", err)
+	}
+	if err := db.First(&schema.Content{}, "id = ?", child.ContentID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("content of the deleted reply is still there: %v", err)
+	}
+}
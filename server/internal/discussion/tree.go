@@ -0,0 +1,73 @@
+package discussion
+
+import (
+	"funcedup/internal/schema"
+
+	"github.com/google/uuid"
+)
+
+// DiscussionSummary is a discussion together with its total reply count.
+type DiscussionSummary struct {
+	schema.Discussion
+	ReplyCount int64 `json:"replyCount"`
+}
+
+// DiscussionTree is a discussion with its replies nested by parent.
+type DiscussionTree struct {
+	schema.Discussion
+	ReplyCount int          `json:"replyCount"`
+	Replies    []*ReplyNode `json:"replies"`
+}
+
+// ReplyNode is a single reply, its content and the replies made to it.
+type ReplyNode struct {
+	schema.DiscusionReply
+	Content    *schema.Content `json:"content"`
+	ReplyCount int             `json:"replyCount"` // number of descendants
+	Replies    []*ReplyNode    `json:"replies"`
+}
+
+// buildTree nests replies below their parents. Replies are expected in
+// chronological order, which is kept within each level. Replies whose parent
+// is missing are treated as top level replies.
+func buildTree(discussion schema.Discussion, replies []schema.DiscusionReply, contents map[uuid.UUID]*schema.Content) *DiscussionTree {
+	nodes := make(map[uuid.UUID]*ReplyNode, len(replies))
+	for _, reply := range replies {
+		nodes[reply.ID] = &ReplyNode{
+			DiscusionReply: reply,
+			Content:        contents[reply.ContentID],
+			Replies:        []*ReplyNode{},
+		}
+	}
+
+	tree := &DiscussionTree{
+		Discussion: discussion,
+		ReplyCount: len(replies),
+		Replies:    []*ReplyNode{},
+	}
+	for _, reply := range replies {
+		node := nodes[reply.ID]
+		if reply.ParentID != nil {
+			if parent, ok := nodes[*reply.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		tree.Replies = append(tree.Replies, node)
+	}
+
+	for _, node := range tree.Replies {
+		countDescendants(node)
+	}
+
+	return tree
+}
+
+func countDescendants(node *ReplyNode) int {
+	count := 0
+	for _, child := range node.Replies {
+		count += 1 + countDescendants(child)
+	}
+	node.ReplyCount = count
+	return count
+}
@@ -0,0 +1,107 @@
+package discussion
+
+import (
+	"testing"
+	"time"
+
+	"funcedup/internal/schema"
+
+	"github.com/google/uuid"
+)
+
+func testReply(id uuid.UUID, parent *uuid.UUID) schema.DiscusionReply {
+	reply := schema.DiscusionReply{ContentID: uuid.New(), ParentID: parent}
+	reply.ID = id
+	reply.CreatedAt = time.Now()
+	return reply
+}
+
+// shape renders a tree as nested ids, e.g. "a(b c(d)) e".
+func shape(nodes []*ReplyNode, names map[uuid.UUID]string) string {
+	out := ""
+	for i, node := range nodes {
+		if i > 0 {
+			out += " "
+		}
+		out += names[node.ID]
+		if len(node.Replies) > 0 {
+			out += "(" + shape(node.Replies, names) + ")"
+		}
+	}
+	return out
+}
+
+func TestBuildTree(t *testing.T) {
+	a, b, c, d, e := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	missing := uuid.New()
+	names := map[uuid.UUID]string{a: "a", b: "b", c: "c", d: "d", e: "e"}
+
+	tests := []struct {
+		name    string
+		replies []schema.DiscusionReply
+		shape   string
+		counts  map[string]int
+	}{
+		{
+			name:    "empty",
+			replies: nil,
+			shape:   "",
+		},
+		{
+			name: "nested in chronological order",
+			replies: []schema.DiscusionReply{
+				testReply(a, nil),
+				testReply(b, &a),
+				testReply(c, &a),
+				testReply(d, &c),
+				testReply(e, nil),
+			},
+			shape:  "a(b c(d)) e",
+			counts: map[string]int{"a": 3, "c": 1, "e": 0},
+		},
+		{
+			name: "missing parent is top level",
+			replies: []schema.DiscusionReply{
+				testReply(a, nil),
+				testReply(b, &missing),
+			},
+			shape:  "a b",
+			counts: map[string]int{"a": 0, "b": 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contents := make(map[uuid.UUID]*schema.Content)
+			for _, reply := range test.replies {
+				contents[reply.ContentID] = &schema.Content{Body: names[reply.ID]}
+			}
+
+			tree := buildTree(schema.Discussion{}, test.replies, contents)
+
+			if got := shape(tree.Replies, names); got != test.shape {
+				t.Errorf("got tree %q, want %q", got, test.shape)
+			}
+			if tree.ReplyCount != len(test.replies) {
+				t.Errorf("got reply count %d, want %d", tree.ReplyCount, len(test.replies))
+			}
+			if tree.Replies == nil {
+				t.Error("replies encode as null instead of []")
+			}
+
+			var walk func(nodes []*ReplyNode)
+			walk = func(nodes []*ReplyNode) {
+				for _, node := range nodes {
+					name := names[node.ID]
+					if want, ok := test.counts[name]; ok && node.ReplyCount != want {
+						t.Errorf("%s has %d descendants, want %d", name, node.ReplyCount, want)
+					}
+					if node.Content == nil || node.Content.Body != name {
+						t.Errorf("%s lost its content", name)
+					}
+					walk(node.Replies)
+				}
+			}
+			walk(tree.Replies)
+		})
+	}
+}
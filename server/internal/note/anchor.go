@@ -0,0 +1,18 @@
+package note
+
+import (
+	"fmt"
+)
+
+// anchorQuote returns the text between start and end in body.
+// Offsets count characters (runes), not bytes, so they line up with what
+// clients see when selecting text.
+func anchorQuote(body string, start int, end int) (string, error) {
+	runes := []rune(body)
+
+	if start < 0 || end > len(runes) || start >= end {
+		return "", fmt.Errorf("anchor range [%d, %d) is outside of content of length %d", start, end, len(runes))
+	}
+
+	return string(runes[start:end]), nil
+}
@@ -0,0 +1,42 @@
+package note
+
+import "testing"
+
+func TestAnchorQuote(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		start int
+		end   int
+		want  string
+		fails bool
+	}{
+		{name: "ascii", body: "hello world", start: 6, end: 11, want: "world"},
+		{name: "whole body", body: "hello", start: 0, end: 5, want: "hello"},
+		// ranges count characters, not bytes
+		{name: "multi byte", body: "λ-calculus ∘ compose", start: 0, end: 10, want: "λ-calculus"},
+		{name: "after multi byte", body: "λ-calculus ∘ compose", start: 11, end: 12, want: "∘"},
+		{name: "emoji", body: "fun 🎉 ctional", start: 4, end: 5, want: "🎉"},
+		{name: "negative start", body: "hello", start: -1, end: 2, fails: true},
+		{name: "end past body", body: "λλ", start: 0, end: 3, fails: true},
+		{name: "empty range", body: "hello", start: 2, end: 2, fails: true},
+		{name: "reversed", body: "hello", start: 3, end: 1, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := anchorQuote(test.body, test.start, test.end)
+			if test.fails {
+				if err == nil {
+					t.Errorf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
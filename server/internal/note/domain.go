@@ -0,0 +1,138 @@
+package note
+
+import (
+	"context"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/metrics"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope    string
+	logger   *zap.Logger
+	config   *Config
+	params   Params
+	counters counters
+}
+
+type counters struct {
+	created *prometheus.CounterVec
+	replies *prometheus.CounterVec
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	DB        *pgconn.Module
+	Metrics   *metrics.Module
+	Server    *server.Module
+}
+
+type Config struct {
+	DefaultPageSize   int    `mapstructure:"default_page_size" validate:"min=1,ltefield=MaxPageSize"`
+	MaxPageSize       int    `mapstructure:"max_page_size" validate:"min=1"`
+	DefaultVisibility string `mapstructure:"default_visibility" validate:"oneof=private followers public"`
+}
+
+const (
+	DefaultPageSize    = 20
+	DefaultMaxPageSize = 100
+	DefaultVisibility  = "private"
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.setupMetrics()
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		DefaultPageSize:   DefaultPageSize,
+		MaxPageSize:       DefaultMaxPageSize,
+		DefaultVisibility: DefaultVisibility,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupMetrics() {
+	d.counters.created = d.params.Metrics.Counter("notes_created_total", "Notes created on content.")
+	d.counters.replies = d.params.Metrics.Counter("note_replies_created_total", "Replies posted to shared notes.")
+}
+
+func (d *Domain) setupRoutes() {
+	content := d.params.Server.APIGroup("/content")
+	content.GET("/:id/notes", d.listNotes)
+	content.POST("/:id/notes", d.createNote, server.RequireAuth)
+
+	g := d.params.Server.APIGroup("/notes")
+	g.GET("/:id", d.getNote)
+	g.PUT("/:id", d.updateNote, server.RequireAuth)
+	g.DELETE("/:id", d.deleteNote, server.RequireAuth)
+	g.POST("/:id/replies", d.createReply, server.RequireAuth)
+	g.DELETE("/:id/replies/:replyId", d.deleteReply, server.RequireAuth)
+
+	// following a user grants access to their followers-only notes
+	users := d.params.Server.AuthGroup("/users")
+	users.POST("/:id/follow", d.follow)
+	users.DELETE("/:id/follow", d.unfollow)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting note domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping note domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Note Configuration -----")
+	d.logger.Debug("DefaultPageSize", zap.Int("default_page_size", d.config.DefaultPageSize))
+	d.logger.Debug("MaxPageSize", zap.Int("max_page_size", d.config.MaxPageSize))
+	d.logger.Debug("DefaultVisibility", zap.String("default_visibility", d.config.DefaultVisibility))
+	d.logger.Debug("-------------------------------")
+}
@@ -0,0 +1,63 @@
+package note
+
+import (
+	"fmt"
+	"net/http"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/server"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// POST /api/v1/users/:id/follow
+func (d *Domain) follow(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	followeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+	if followeeID == userID {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "users cannot follow themselves")
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	err = db.Select("id").First(&schema.User{}, "id = ?", followeeID).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	follow := schema.Follow{FollowerID: userID, FolloweeID: followeeID}
+	err = db.
+		Where("follower_id = ? AND followee_id = ?", follow.FollowerID, follow.FolloweeID).
+		FirstOrCreate(&follow).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, follow)
+}
+
+// DELETE /api/v1/users/:id/follow
+func (d *Domain) unfollow(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	followeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).
+		Where("follower_id = ? AND followee_id = ?", userID, followeeID).
+		Delete(&schema.Follow{}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to unfollow user: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,402 @@
+package note
+
+import (
+	"fmt"
+	"net/http"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/server"
+	"funcedup/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// -------------------------------------------------------------------------
+// Requests
+// -------------------------------------------------------------------------
+
+type CreateNoteRequest struct {
+	Body        string `json:"body" validate:"required"`
+	Visibility  string `json:"visibility" validate:"omitempty,oneof=private followers public"`
+	AnchorStart *int   `json:"anchorStart" validate:"omitempty,min=0,required_with=AnchorEnd"`
+	AnchorEnd   *int   `json:"anchorEnd" validate:"omitempty,min=0,required_with=AnchorStart"`
+}
+
+type UpdateNoteRequest struct {
+	Body       string `json:"body" validate:"required"`
+	Visibility string `json:"visibility" validate:"required,oneof=private followers public"`
+}
+
+type CreateReplyRequest struct {
+	Title string `json:"title" validate:"max=255"`
+	Body  string `json:"body" validate:"required"`
+}
+
+// -------------------------------------------------------------------------
+// Responses
+// -------------------------------------------------------------------------
+
+type NoteSummary struct {
+	schema.Note
+	ReplyCount int64 `json:"replyCount"`
+}
+
+type NoteWithReplies struct {
+	schema.Note
+	Replies []ReplyWithContent `json:"replies"`
+}
+
+type ReplyWithContent struct {
+	schema.NoteReply
+	Content *schema.Content `json:"content"`
+}
+
+// -------------------------------------------------------------------------
+// Handlers
+// -------------------------------------------------------------------------
+
+// GET /api/v1/content/:id/notes
+// Lists the notes on a content that are visible to the current user.
+func (d *Domain) listNotes(c echo.Context) error {
+	viewerID, _ := server.GetUserID(c)
+
+	contentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid content id")
+	}
+
+	var pagination util.Pagination
+	if err := c.Bind(&pagination); err != nil {
+		return err
+	}
+	page := pagination.Normalize(d.config.DefaultPageSize, d.config.MaxPageSize)
+
+	db := d.params.DB.Scoped(c.Request().Context())
+	query := db.Model(&schema.Note{}).
+		Where("notes.content_id = ?", contentID).
+		Scopes(visibleTo(viewerID))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fmt.Errorf("failed to count notes: %w", err)
+	}
+
+	replyCount := db.Model(&schema.NoteReply{}).
+		Select("count(*)").
+		Where("note_replies.note_id = notes.id")
+
+	var notes []NoteSummary
+	err = query.
+		Select("notes.*, (?) AS reply_count", replyCount).
+		Order("notes.anchor_start ASC NULLS FIRST, notes.created_at ASC").
+		Scopes(page.Scope()).
+		Scan(&notes).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, util.NewPage(notes, page, total))
+}
+
+// POST /api/v1/content/:id/notes
+func (d *Domain) createNote(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	contentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid content id")
+	}
+
+	var req CreateNoteRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	var content schema.Content
+	err = db.Select("id", "body").First(&content, "id = ?", contentID).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch content: %w", err)
+	}
+
+	note := schema.Note{
+		OwnerID:    userID,
+		ContentID:  contentID,
+		Body:       req.Body,
+		Visibility: req.Visibility,
+	}
+	if note.Visibility == "" {
+		note.Visibility = d.config.DefaultVisibility
+	}
+
+	if req.AnchorStart != nil && req.AnchorEnd != nil {
+		quote, err := anchorQuote(content.Body, *req.AnchorStart, *req.AnchorEnd)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+		note.AnchorStart = req.AnchorStart
+		note.AnchorEnd = req.AnchorEnd
+		note.Quote = quote
+	}
+
+	if err := db.Create(&note).Error; err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+	d.counters.created.WithLabelValues().Inc()
+
+	return c.JSON(http.StatusCreated, note)
+}
+
+// GET /api/v1/notes/:id
+func (d *Domain) getNote(c echo.Context) error {
+	note, err := d.findVisibleNote(c)
+	if err != nil {
+		return err
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	var replies []schema.NoteReply
+	if err := db.Where("note_id = ?", note.ID).Order("created_at ASC").Find(&replies).Error; err != nil {
+		return fmt.Errorf("failed to fetch note replies: %w", err)
+	}
+
+	contentIDs := make([]uuid.UUID, 0, len(replies))
+	for _, reply := range replies {
+		contentIDs = append(contentIDs, reply.ContentID)
+	}
+
+	contents := make(map[uuid.UUID]*schema.Content, len(contentIDs))
+	if len(contentIDs) > 0 {
+		var rows []schema.Content
+		if err := db.Where("id IN ?", contentIDs).Find(&rows).Error; err != nil {
+			return fmt.Errorf("failed to fetch note reply content: %w", err)
+		}
+		for i := range rows {
+			contents[rows[i].ID] = &rows[i]
+		}
+	}
+
+	response := NoteWithReplies{
+		Note:    *note,
+		Replies: make([]ReplyWithContent, 0, len(replies)),
+	}
+	for _, reply := range replies {
+		response.Replies = append(response.Replies, ReplyWithContent{
+			NoteReply: reply,
+			Content:   contents[reply.ContentID],
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// PUT /api/v1/notes/:id
+// The anchor of a note is fixed once created, only body and visibility change.
+func (d *Domain) updateNote(c echo.Context) error {
+	note, err := d.findOwnedNote(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateNoteRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).
+		Model(note).
+		Updates(map[string]interface{}{"body": req.Body, "visibility": req.Visibility}).
+		Error
+	if err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+// DELETE /api/v1/notes/:id
+// Removes the note together with all replies and their content.
+func (d *Domain) deleteNote(c echo.Context) error {
+	note, err := d.findOwnedNote(c)
+	if err != nil {
+		return err
+	}
+
+	err = d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		replyContent := tx.Model(&schema.NoteReply{}).Select("content_id").Where("note_id = ?", note.ID)
+		if err := tx.Where("id IN (?)", replyContent).Delete(&schema.Content{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("note_id = ?", note.ID).Delete(&schema.NoteReply{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(note).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// POST /api/v1/notes/:id/replies
+// Only shared notes accept replies.
+func (d *Domain) createReply(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	note, err := d.findVisibleNote(c)
+	if err != nil {
+		return err
+	}
+	if note.Visibility == schema.NoteVisibilityPrivate {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "private notes do not accept replies")
+	}
+
+	var req CreateReplyRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if err := c.Validate(&req); err != nil {
+		return err
+	}
+
+	var response ReplyWithContent
+	err = d.params.DB.Scoped(c.Request().Context()).Transaction(func(tx *gorm.DB) error {
+		content := schema.Content{
+			Title:   req.Title,
+			Body:    req.Body,
+			OwnerID: userID,
+		}
+		if err := tx.Create(&content).Error; err != nil {
+			return err
+		}
+
+		reply := schema.NoteReply{
+			OwnerID:   userID,
+			NoteID:    note.ID,
+			ContentID: content.ID,
+		}
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+
+		response = ReplyWithContent{NoteReply: reply, Content: &content}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create note reply: %w", err)
+	}
+	d.counters.replies.WithLabelValues().Inc()
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+// DELETE /api/v1/notes/:id/replies/:replyId
+func (d *Domain) deleteReply(c echo.Context) error {
+	userID, _ := server.GetUserID(c)
+
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid note id")
+	}
+	replyID, err := uuid.Parse(c.Param("replyId"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid reply id")
+	}
+
+	db := d.params.DB.Scoped(c.Request().Context())
+
+	var reply schema.NoteReply
+	err = db.First(&reply, "id = ? AND note_id = ?", replyID, noteID).Error
+	if err != nil {
+		return fmt.Errorf("failed to fetch note reply: %w", err)
+	}
+	if reply.OwnerID != userID {
+		return echo.NewHTTPError(http.StatusForbidden, "reply is owned by another user")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&reply).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&schema.Content{}, "id = ?", reply.ContentID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete note reply: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// visibleTo limits a note query to the notes the viewer may read: their own,
+// public ones and followers-only notes of users they follow.
+// Pass uuid.Nil for anonymous viewers.
+func visibleTo(viewerID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		following := db.Session(&gorm.Session{NewDB: true}).
+			Model(&schema.Follow{}).
+			Select("1").
+			Where("follows.follower_id = ? AND follows.followee_id = notes.owner_id", viewerID)
+
+		return db.Where(
+			"(notes.owner_id = ? OR notes.visibility = ? OR (notes.visibility = ? AND EXISTS (?)))",
+			viewerID, schema.NoteVisibilityPublic, schema.NoteVisibilityFollowers, following,
+		)
+	}
+}
+
+// findVisibleNote loads the note referenced by the :id path parameter if the
+// current user may read it. Hidden notes are reported as not found.
+func (d *Domain) findVisibleNote(c echo.Context) (*schema.Note, error) {
+	viewerID, _ := server.GetUserID(c)
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid note id")
+	}
+
+	var note schema.Note
+	err = d.params.DB.Scoped(c.Request().Context()).
+		Scopes(visibleTo(viewerID)).
+		First(&note, "notes.id = ?", id).
+		Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch note: %w", err)
+	}
+
+	return &note, nil
+}
+
+// findOwnedNote loads the note referenced by the :id path parameter and checks
+// that it belongs to the authenticated user.
+func (d *Domain) findOwnedNote(c echo.Context) (*schema.Note, error) {
+	userID, ok := server.GetUserID(c)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized)
+	}
+
+	note, err := d.findVisibleNote(c)
+	if err != nil {
+		return nil, err
+	}
+	if note.OwnerID != userID {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "note is owned by another user")
+	}
+
+	return note, nil
+}
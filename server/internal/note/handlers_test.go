@@ -0,0 +1,52 @@
+package note
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"funcedup/internal/schema"
+	"funcedup/internal/schema/schematest"
+
+	"github.com/google/uuid"
+)
+
+func TestVisibleTo(t *testing.T) {
+	db := schematest.Open(t)
+	owner, follower, stranger := uuid.New(), uuid.New(), uuid.New()
+
+	if err := db.Create(&schema.Follow{FollowerID: follower, FolloweeID: owner}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// the stranger follows someone else, that must not open up owner's notes
+	if err := db.Create(&schema.Follow{FollowerID: stranger, FolloweeID: follower}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, visibility := range []string{schema.NoteVisibilityPrivate, schema.NoteVisibilityFollowers, schema.NoteVisibilityPublic} {
+		note := schema.Note{OwnerID: owner, ContentID: uuid.New(), Body: visibility, Visibility: visibility}
+		if err := db.Create(&note).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		viewer uuid.UUID
+		want   []string
+	}{
+		{"owner", owner, []string{"followers", "private", "public"}},
+		{"follower", follower, []string{"followers", "public"}},
+		{"stranger", stranger, []string{"public"}},
+		{"anonymous", uuid.Nil, []string{"public"}},
+	}
+	for _, test := range tests {
+		var got []string
+		if err := db.Model(&schema.Note{}).Scopes(visibleTo(test.viewer)).Pluck("body", &got).Error; err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s sees %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -6,6 +6,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// Models returns every model of the schema.
+func Models() []interface{} {
+	return []interface{}{
+		&User{},
+		&Content{},
+		&Discussion{},
+		&DiscusionReply{},
+		&Note{},
+		&NoteReply{},
+		&Follow{},
+		&AppMetadata{},
+		&Tenant{},
+		&Tag{},
+		&ContentTag{},
+	}
+}
+
 type BaseModel struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -13,13 +30,30 @@ type BaseModel struct {
 	// DeletedAt int  `json:"deletedAt" gorm:"index"`
 }
 
-type User struct {
+// TenantBaseModel is the BaseModel of rows owned by a tenant.
+// With database.tenancy: row, pgconn sets and filters TenantID from the
+// tenant on the query context, it is nil for single tenant deployments.
+type TenantBaseModel struct {
 	BaseModel
+	TenantID *uuid.UUID `json:"-" gorm:"type:uuid;index"`
+}
+
+// Roles a user can have.
+const (
+	UserRoleMember = "member"
+	UserRoleAdmin  = "admin"
+)
+
+type User struct {
+	TenantBaseModel
 
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"-"`
-	Points       int    `json:"points"`
+	// unique per tenant, see migration 0007
+	Username     string     `json:"username" gorm:"uniqueIndex:idx_users_tenant_username,expression:tenant_id\\,username,option:NULLS NOT DISTINCT"`
+	Email        string     `json:"email" gorm:"uniqueIndex:idx_users_tenant_email,expression:tenant_id\\,email,option:NULLS NOT DISTINCT"`
+	PasswordHash string     `json:"-"`
+	Points       int        `json:"points"`
+	Role         string     `json:"role" gorm:"default:member"`
+	DisabledAt   *time.Time `json:"disabledAt"`
 
 	// Discussions      []Discussion     `json:"discussions" gorm:"foreignKey:OwnerID"`      // all discussions the user owns
 	// DiscusionReplies []DiscusionReply `json:"discusionReplies" gorm:"foreignKey:OwnerID"` // all replies the user has made
@@ -28,11 +62,19 @@ type User struct {
 	// Content 			[]Content 		 `json:"posts" gorm:"foreignKey:OwnerID"`
 }
 
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
 type Discussion struct {
-	BaseModel
+	TenantBaseModel
 
-	OwnerID   uuid.UUID `json:"ownerId"`
-	ContentID uuid.UUID `json:"contentId"`
+	OwnerID   uuid.UUID `json:"ownerId" gorm:"type:uuid"`
+	ContentID uuid.UUID `json:"contentId" gorm:"type:uuid"`
 
 	// Owner   *User            `json:"owner" gorm:"foreignKey:OwnerID"`
 	// Content *Content         `json:"content" gorm:"foreignKey:ContentID"`
@@ -40,22 +82,38 @@ type Discussion struct {
 }
 
 type DiscusionReply struct {
-	BaseModel
+	TenantBaseModel
 
-	OwnerID      uuid.UUID `json:"ownerId"`
-	DiscussionID uuid.UUID `json:"discussionId"`
-	ContentID    uuid.UUID `json:"contentId"`
+	OwnerID      uuid.UUID  `json:"ownerId" gorm:"type:uuid"`
+	DiscussionID uuid.UUID  `json:"discussionId" gorm:"type:uuid"`
+	ContentID    uuid.UUID  `json:"contentId" gorm:"type:uuid"`
+	ParentID     *uuid.UUID `json:"parentId" gorm:"type:uuid;index"` // nil for top level replies
 
 	// Owner   *User `json:"owner" gorm:"foreignKey:OwnerID"`
 	// Discussion   *Discussion `json:"discussion" gorm:"foreignKey:DiscussionID"`
 	// Content   *Content `json:"content" gorm:"foreignKey:ContentID"`
 }
 
+// Who besides the owner can read a note.
+const (
+	NoteVisibilityPrivate   = "private"
+	NoteVisibilityFollowers = "followers"
+	NoteVisibilityPublic    = "public"
+)
+
 type Note struct {
-	BaseModel
+	TenantBaseModel
 
-	OwnerID   uuid.UUID `json:"ownerId"`
-	ContentID uuid.UUID `json:"contentId"`
+	OwnerID    uuid.UUID `json:"ownerId" gorm:"type:uuid"`
+	ContentID  uuid.UUID `json:"contentId" gorm:"type:uuid"`
+	Body       string    `json:"body"`
+	Visibility string    `json:"visibility" gorm:"default:private;index"`
+
+	// optional character range within Content.Body the note is anchored to,
+	// Quote keeps the anchored text as it was when the note was written
+	AnchorStart *int   `json:"anchorStart"`
+	AnchorEnd   *int   `json:"anchorEnd"`
+	Quote       string `json:"quote"`
 
 	// Owner   *User `json:"owner" gorm:"foreignKey:OwnerID"`
 	// Content   *Content `json:"content" gorm:"foreignKey:ContentID"`
@@ -63,23 +121,30 @@ type Note struct {
 }
 
 type NoteReply struct {
-	BaseModel
+	TenantBaseModel
 
-	OwnerID   uuid.UUID `json:"ownerId"`
-	NoteID    uuid.UUID `json:"noteId"`
-	ContentID uuid.UUID `json:"contentId"`
+	OwnerID   uuid.UUID `json:"ownerId" gorm:"type:uuid"`
+	NoteID    uuid.UUID `json:"noteId" gorm:"type:uuid"`
+	ContentID uuid.UUID `json:"contentId" gorm:"type:uuid"`
 
 	// Owner   *User `json:"owner" gorm:"foreignKey:OwnerID"`
 	// Note   *Note `json:"note" gorm:"foreignKey:NoteID"`
 	// Content   *Content `json:"content" gorm:"foreignKey:ContentID"`
 }
 
+type Follow struct {
+	TenantBaseModel
+
+	FollowerID uuid.UUID `json:"followerId" gorm:"type:uuid;uniqueIndex:idx_follows_pair"`
+	FolloweeID uuid.UUID `json:"followeeId" gorm:"type:uuid;uniqueIndex:idx_follows_pair;index"`
+}
+
 type Content struct {
-	BaseModel
+	TenantBaseModel
 
 	Title   string    `json:"title"`
 	Body    string    `json:"body"`
-	OwnerID uuid.UUID `json:"ownerId"`
+	OwnerID uuid.UUID `json:"ownerId" gorm:"type:uuid"`
 
 	// optional
 	// Owner          *User            `json:"owner" gorm:"foreignKey:OwnerID"`
@@ -92,15 +157,45 @@ type Content struct {
 }
 
 type ContentTag struct {
-	BaseModel
-	ContentID    uuid.UUID `json:"contentId"`
-	TagID        uuid.UUID `json:"tagId"`
+	TenantBaseModel
+	ContentID    uuid.UUID `json:"contentId" gorm:"type:uuid"`
+	TagID        uuid.UUID `json:"tagId" gorm:"type:uuid"`
 	Relationship string    `json:"relationship"` // potentially use enums for relationships
 }
 
 type Tag struct {
-	BaseModel
-	Name string `json:"name"`
+	TenantBaseModel
+	// unique per tenant, see migration 0008
+	Name string `json:"name" gorm:"uniqueIndex:idx_tags_tenant_name,expression:tenant_id\\,name,option:NULLS NOT DISTINCT"`
 
 	Content []Content `json:"contents" gorm:"many2many:content_tags"`
 }
+
+// Tenant of a multi-tenant deployment, see server.is_multi_tenant.
+// Requests name their tenant by Slug.
+type Tenant struct {
+	BaseModel
+
+	Slug       string     `json:"slug" gorm:"uniqueIndex"`
+	Name       string     `json:"name"`
+	Schema     string     `json:"schema"` // postgres schema, only used with database.tenancy: schema
+	DisabledAt *time.Time `json:"disabledAt"`
+}
+
+func (t *Tenant) IsDisabled() bool {
+	return t.DisabledAt != nil
+}
+
+// Key/value settings stored alongside the data, e.g. the environment flag.
+const (
+	AppMetadataEnvironment = "environment"
+)
+
+type AppMetadata struct {
+	Key   string `json:"key" gorm:"primaryKey"`
+	Value string `json:"value"`
+}
+
+func (AppMetadata) TableName() string {
+	return "app_metadata"
+}
@@ -0,0 +1 @@
+-- Nothing to undo, the adopted columns belong to 0001 since.
@@ -0,0 +1,65 @@
+-- Databases created by GORM AutoMigrate before versioned migrations already
+-- have most tables, which 0001 skips with IF NOT EXISTS. Add the columns later
+-- models brought along so 0001 can index them, and clear duplicates out of the
+-- way of its unique indexes. No-op on new databases and on databases migrated
+-- by 0001 already, where it runs after the other versions.
+
+ALTER TABLE IF EXISTS notes
+    ADD COLUMN IF NOT EXISTS body text,
+    ADD COLUMN IF NOT EXISTS visibility text DEFAULT 'private',
+    ADD COLUMN IF NOT EXISTS anchor_start bigint,
+    ADD COLUMN IF NOT EXISTS anchor_end bigint,
+    ADD COLUMN IF NOT EXISTS quote text;
+
+ALTER TABLE IF EXISTS discusion_replies
+    ADD COLUMN IF NOT EXISTS parent_id uuid;
+
+DO $$
+BEGIN
+    -- AutoMigrate did not make tag names unique, merge duplicates into the
+    -- oldest tag
+    IF to_regclass('tags') IS NOT NULL
+        AND to_regclass('idx_tags_name') IS NULL
+        AND to_regclass('idx_tags_tenant_name') IS NULL THEN
+
+        CREATE TEMP TABLE duplicate_tags ON COMMIT DROP AS
+        SELECT id, keep FROM (
+            SELECT id, first_value(id) OVER (PARTITION BY name ORDER BY created_at, id) AS keep
+            FROM tags
+            WHERE name IS NOT NULL
+        ) ranked
+        WHERE id <> keep;
+
+        -- content tagged with several duplicates keeps a single link
+        DELETE FROM content_tags
+        USING (
+            SELECT content_tags.ctid AS row_id,
+                   row_number() OVER (
+                       PARTITION BY content_tags.content_id, coalesce(duplicate_tags.keep, content_tags.tag_id)
+                       ORDER BY content_tags.ctid
+                   ) AS n
+            FROM content_tags
+            LEFT JOIN duplicate_tags ON duplicate_tags.id = content_tags.tag_id
+        ) ranked
+        WHERE content_tags.ctid = ranked.row_id AND ranked.n > 1;
+
+        UPDATE content_tags SET tag_id = duplicate_tags.keep
+        FROM duplicate_tags
+        WHERE content_tags.tag_id = duplicate_tags.id;
+
+        DELETE FROM tags WHERE id IN (SELECT id FROM duplicate_tags);
+    END IF;
+
+    -- duplicate accounts can not be merged automatically
+    IF to_regclass('users') IS NOT NULL
+        AND to_regclass('idx_users_username') IS NULL
+        AND to_regclass('idx_users_tenant_username') IS NULL THEN
+
+        IF EXISTS (SELECT 1 FROM users WHERE username IS NOT NULL GROUP BY username HAVING count(*) > 1) THEN
+            RAISE EXCEPTION 'several users share a username, rename them before migrating';
+        END IF;
+        IF EXISTS (SELECT 1 FROM users WHERE email IS NOT NULL GROUP BY email HAVING count(*) > 1) THEN
+            RAISE EXCEPTION 'several users share an email, change them before migrating';
+        END IF;
+    END IF;
+END $$;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS note_replies;
+DROP TABLE IF EXISTS notes;
+DROP TABLE IF EXISTS discusion_replies;
+DROP TABLE IF EXISTS discussions;
+DROP TABLE IF EXISTS content_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS contents;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,92 @@
+-- Initial schema, matching what GORM AutoMigrate created so far.
+-- IF NOT EXISTS lets databases created by AutoMigrate adopt versioned migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    username      text,
+    email         text,
+    password_hash text,
+    points        bigint
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS contents (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    title      text,
+    body       text,
+    owner_id   uuid
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    name       text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+
+CREATE TABLE IF NOT EXISTS content_tags (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    content_id   uuid,
+    tag_id       uuid,
+    relationship text
+);
+
+CREATE TABLE IF NOT EXISTS discussions (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    owner_id   uuid,
+    content_id uuid
+);
+
+CREATE TABLE IF NOT EXISTS discusion_replies (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    owner_id      uuid,
+    discussion_id uuid,
+    content_id    uuid,
+    parent_id     uuid
+);
+CREATE INDEX IF NOT EXISTS idx_discusion_replies_parent_id ON discusion_replies (parent_id);
+
+CREATE TABLE IF NOT EXISTS notes (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at   timestamptz,
+    updated_at   timestamptz,
+    owner_id     uuid,
+    content_id   uuid,
+    body         text,
+    visibility   text DEFAULT 'private',
+    anchor_start bigint,
+    anchor_end   bigint,
+    quote        text
+);
+CREATE INDEX IF NOT EXISTS idx_notes_visibility ON notes (visibility);
+
+CREATE TABLE IF NOT EXISTS note_replies (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    owner_id   uuid,
+    note_id    uuid,
+    content_id uuid
+);
+
+CREATE TABLE IF NOT EXISTS follows (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    follower_id uuid,
+    followee_id uuid
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_follows_pair ON follows (follower_id, followee_id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
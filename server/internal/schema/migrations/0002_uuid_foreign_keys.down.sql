@@ -0,0 +1,24 @@
+ALTER TABLE contents
+    ALTER COLUMN owner_id TYPE text USING owner_id::text;
+
+ALTER TABLE content_tags
+    ALTER COLUMN content_id TYPE text USING content_id::text,
+    ALTER COLUMN tag_id TYPE text USING tag_id::text;
+
+ALTER TABLE discussions
+    ALTER COLUMN owner_id TYPE text USING owner_id::text,
+    ALTER COLUMN content_id TYPE text USING content_id::text;
+
+ALTER TABLE discusion_replies
+    ALTER COLUMN owner_id TYPE text USING owner_id::text,
+    ALTER COLUMN discussion_id TYPE text USING discussion_id::text,
+    ALTER COLUMN content_id TYPE text USING content_id::text;
+
+ALTER TABLE notes
+    ALTER COLUMN owner_id TYPE text USING owner_id::text,
+    ALTER COLUMN content_id TYPE text USING content_id::text;
+
+ALTER TABLE note_replies
+    ALTER COLUMN owner_id TYPE text USING owner_id::text,
+    ALTER COLUMN note_id TYPE text USING note_id::text,
+    ALTER COLUMN content_id TYPE text USING content_id::text;
@@ -0,0 +1,27 @@
+-- GORM AutoMigrate stored uuid foreign keys as text, convert them so joins
+-- against uuid primary keys work. No-op on databases created by 0001.
+
+ALTER TABLE contents
+    ALTER COLUMN owner_id TYPE uuid USING owner_id::uuid;
+
+ALTER TABLE content_tags
+    ALTER COLUMN content_id TYPE uuid USING content_id::uuid,
+    ALTER COLUMN tag_id TYPE uuid USING tag_id::uuid;
+
+ALTER TABLE discussions
+    ALTER COLUMN owner_id TYPE uuid USING owner_id::uuid,
+    ALTER COLUMN content_id TYPE uuid USING content_id::uuid;
+
+ALTER TABLE discusion_replies
+    ALTER COLUMN owner_id TYPE uuid USING owner_id::uuid,
+    ALTER COLUMN discussion_id TYPE uuid USING discussion_id::uuid,
+    ALTER COLUMN content_id TYPE uuid USING content_id::uuid;
+
+ALTER TABLE notes
+    ALTER COLUMN owner_id TYPE uuid USING owner_id::uuid,
+    ALTER COLUMN content_id TYPE uuid USING content_id::uuid;
+
+ALTER TABLE note_replies
+    ALTER COLUMN owner_id TYPE uuid USING owner_id::uuid,
+    ALTER COLUMN note_id TYPE uuid USING note_id::uuid,
+    ALTER COLUMN content_id TYPE uuid USING content_id::uuid;
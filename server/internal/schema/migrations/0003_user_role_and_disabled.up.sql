@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role text DEFAULT 'member',
+    ADD COLUMN IF NOT EXISTS disabled_at timestamptz;
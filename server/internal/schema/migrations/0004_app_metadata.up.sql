@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS app_metadata (
+    key   text PRIMARY KEY,
+    value text
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS tenants (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    slug        text,
+    name        text,
+    schema      text,
+    disabled_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_slug ON tenants (slug);
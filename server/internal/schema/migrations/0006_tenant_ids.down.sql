@@ -0,0 +1,9 @@
+ALTER TABLE follows DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE note_replies DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE notes DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE discusion_replies DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE discussions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE content_tags DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE tags DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE contents DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,29 @@
+-- Rows owned by a tenant, see database.tenancy: row.
+-- NULL for single tenant deployments.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id);
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_contents_tenant_id ON contents (tenant_id);
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_tags_tenant_id ON tags (tenant_id);
+
+ALTER TABLE content_tags ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_content_tags_tenant_id ON content_tags (tenant_id);
+
+ALTER TABLE discussions ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_discussions_tenant_id ON discussions (tenant_id);
+
+ALTER TABLE discusion_replies ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_discusion_replies_tenant_id ON discusion_replies (tenant_id);
+
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_notes_tenant_id ON notes (tenant_id);
+
+ALTER TABLE note_replies ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_note_replies_tenant_id ON note_replies (tenant_id);
+
+ALTER TABLE follows ADD COLUMN IF NOT EXISTS tenant_id uuid REFERENCES tenants (id);
+CREATE INDEX IF NOT EXISTS idx_follows_tenant_id ON follows (tenant_id);
@@ -0,0 +1,9 @@
+-- Usernames and emails are unique per tenant, see database.tenancy: row.
+-- NULLS NOT DISTINCT (postgres 15) keeps them unique when tenant_id is NULL,
+-- i.e. single tenant deployments and tenant schemas.
+
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_username ON users (tenant_id, username) NULLS NOT DISTINCT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email ON users (tenant_id, email) NULLS NOT DISTINCT;
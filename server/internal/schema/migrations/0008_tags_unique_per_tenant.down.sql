@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tags_tenant_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
@@ -0,0 +1,5 @@
+-- Tag names are unique per tenant, like usernames and emails, see 0007.
+
+DROP INDEX IF EXISTS idx_tags_name;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_tenant_name ON tags (tenant_id, name) NULLS NOT DISTINCT;
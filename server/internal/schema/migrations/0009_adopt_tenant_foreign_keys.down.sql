@@ -0,0 +1 @@
+-- Nothing to undo, 0006 owns the foreign keys.
@@ -0,0 +1,27 @@
+-- tenant_id columns created by GORM AutoMigrate lack the foreign key of 0006,
+-- which ADD COLUMN IF NOT EXISTS skipped. Add it where it is missing.
+
+DO $$
+DECLARE
+    tbl text;
+BEGIN
+    FOREACH tbl IN ARRAY ARRAY[
+        'users', 'contents', 'tags', 'content_tags', 'discussions',
+        'discusion_replies', 'notes', 'note_replies', 'follows'
+    ] LOOP
+        IF NOT EXISTS (
+            SELECT 1
+            FROM pg_constraint
+            JOIN pg_attribute ON pg_attribute.attrelid = pg_constraint.conrelid
+                AND pg_attribute.attnum = ANY (pg_constraint.conkey)
+            WHERE pg_constraint.contype = 'f'
+                AND pg_constraint.conrelid = tbl::regclass
+                AND pg_attribute.attname = 'tenant_id'
+        ) THEN
+            EXECUTE format(
+                'ALTER TABLE %I ADD CONSTRAINT %I FOREIGN KEY (tenant_id) REFERENCES tenants (id)',
+                tbl, 'fk_' || tbl || '_tenant'
+            );
+        END IF;
+    END LOOP;
+END $$;
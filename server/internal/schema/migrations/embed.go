@@ -0,0 +1,14 @@
+// Package migrations holds the versioned SQL migrations of the schema package.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql and
+// must never be edited once applied, add a new version instead.
+package migrations
+
+import "embed"
+
+// Shared are the versions creating the tables every tenant shares, e.g.
+// tenants, or concerning the public schema only. Tenant schemas skip them and
+// reach the shared tables through the search path.
+var Shared = []int64{0, 4, 5}
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,76 @@
+// Package schematest provides a database holding the application schema for
+// the tests of the domains.
+package schematest
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/pgconn"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open connects to the test database and migrates the models into a schema
+// of their own, dropped when the test ends. Like the tests of pkg/pgconn it
+// only runs with SERVER_TEST_DATABASE_HOST set, see scripts/test-rls.sh, the
+// other SERVER_TEST_DATABASE_* variables default to the docker-compose setup.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	host := os.Getenv("SERVER_TEST_DATABASE_HOST")
+	if host == "" {
+		t.Skip("SERVER_TEST_DATABASE_HOST is not set")
+	}
+	env := func(name string, fallback string) string {
+		if value := os.Getenv("SERVER_TEST_DATABASE_" + name); value != "" {
+			return value
+		}
+		return fallback
+	}
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		host,
+		env("PORT", strconv.Itoa(pgconn.DefaultPort)),
+		env("USER", pgconn.DefaultUser),
+		env("PASSWORD", pgconn.DefaultPassword),
+		env("DBNAME", pgconn.DefaultDbName),
+		env("SSLMODE", "disable"),
+	)
+	config := &gorm.Config{Logger: logger.Discard, TranslateError: true}
+
+	owner, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "schematest_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := owner.Exec("CREATE SCHEMA " + name).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		owner.Exec("DROP SCHEMA IF EXISTS " + name + " CASCADE")
+		if sqlDB, err := owner.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+name), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(schema.Models()...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -2,7 +2,12 @@ package seeder
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
+
+	"funcedup/pkg/config"
 	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
 
 	"github.com/spf13/viper"
 	"go.uber.org/fx"
@@ -14,6 +19,9 @@ type Domain struct {
 	logger *zap.Logger
 	config *Config
 	params Params
+
+	// set once onStart is done, whether or not seeding succeeded
+	finished atomic.Bool
 }
 
 type Params struct {
@@ -24,9 +32,23 @@ type Params struct {
 }
 
 type Config struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	Profile         string `mapstructure:"profile" validate:"oneof=minimal demo load-test fixtures"`
+	Required        bool   `mapstructure:"required"`
+	DefaultPassword string `mapstructure:"default_password" validate:"min=8"`
+	FixturesPath    string `mapstructure:"fixtures_path"`
+	Tenant          string `mapstructure:"tenant"`
+
+	Generator GeneratorConfig `mapstructure:"generator"`
 }
 
-const ()
+const (
+	DefaultEnabled      = false
+	DefaultProfile      = ProfileMinimal
+	DefaultRequired     = false
+	DefaultSeedPassword = "testtesttest"
+	DefaultFixturesPath = "./fixtures"
+)
 
 // ! Domain ---------------------------------------------------------------
 
@@ -41,6 +63,9 @@ func InjectDomain(scope string) fx.Option {
 
 			return m
 		}),
+		fx.Provide(server.AsHealthCheck(func(d *Domain) server.HealthCheck {
+			return server.HealthCheck{Name: "seeder", Check: d.checkFinished}
+		})),
 		fx.Invoke(func(m *Domain, p Params) {
 			p.Lifecycle.Append(
 				fx.Hook{
@@ -59,21 +84,34 @@ func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
 }
 
 func (d *Domain) setupConfig(scope string) *Config {
-	// viper.SetDefault(util.GetConfigPath("global", "client_domain"), defaultClientDomain)
-	// viper.SetDefault(util.GetConfigPath(scope, "jwt_auth_scope"), defaultJWTAuthScope)
-	// viper.SetDefault(util.GetConfigPath(scope, "jwt_email_scope"), defaultJWTEmailConfirmationScope)
-	// viper.SetDefault(util.GetConfigPath(scope, "jwt_pw_reset_scope"), defaultJWTPasswordResetScope)
-
-	return &Config{
-		// ClientDomain:             viper.GetString(util.GetConfigPath("global", "client_domain")),
-		// JWTAuthScope:              viper.GetString(util.GetConfigPath(scope, "jwt_auth_scope")),
-		// JWTEmailConfirmationScope: viper.GetString(util.GetConfigPath(scope, "jwt_email_scope")),
-		// JWTPasswordResetScope:     viper.GetString(util.GetConfigPath(scope, "jwt_pw_reset_scope")),
+	c := &Config{
+		Enabled:         DefaultEnabled,
+		Profile:         DefaultProfile,
+		Required:        DefaultRequired,
+		DefaultPassword: DefaultSeedPassword,
+		FixturesPath:    DefaultFixturesPath,
+
+		Generator: GeneratorConfig{
+			Seed:                 DefaultGeneratorSeed,
+			Users:                DefaultGeneratorUsers,
+			Tags:                 DefaultGeneratorTags,
+			ContentPerUser:       DefaultGeneratorContentPerUser,
+			TagsPerContent:       DefaultGeneratorTagsPerContent,
+			RepliesPerDiscussion: DefaultGeneratorRepliesPerDiscussion,
+			MaxReplyDepth:        DefaultGeneratorMaxReplyDepth,
+			NotesPerContent:      DefaultGeneratorNotesPerContent,
+			FollowsPerUser:       DefaultGeneratorFollowsPerUser,
+			BatchSize:            DefaultGeneratorBatchSize,
+		},
 	}
+	config.Load(scope, c)
+
+	return c
 }
 
 func (d *Domain) onStart(ctx context.Context) error {
 	d.logger.Info("Starting seeder domain.")
+	defer d.finished.Store(true)
 
 	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
 		d.logConfigurations()
@@ -82,19 +120,49 @@ func (d *Domain) onStart(ctx context.Context) error {
 	// d.logger.Info("Intializing essential data.")
 	// init data here
 
-	d.logger.Info("Seeding test data.")
-	d.SeedAll()
+	if !d.config.Enabled {
+		d.logger.Info("Seeding disabled.")
+		return nil
+	}
+
+	// a required profile aborts startup, otherwise failures are only logged
+	err := d.GuardProduction(ctx)
+	if err == nil {
+		err = d.SeedProfile(d.config.Profile)
+	}
+	if err != nil && d.config.Required {
+		return err
+	}
+	if err != nil {
+		d.logger.Error("Skipping seed data.", zap.Error(err))
+	}
+
+	return nil
+}
 
+// checkFinished keeps the server from reporting ready before seed data exists.
+func (d *Domain) checkFinished(context.Context) error {
+	if !d.finished.Load() {
+		return errors.New("seeding in progress")
+	}
 	return nil
 }
 
 func (m *Domain) onStop(ctx context.Context) error {
-	m.logger.Info("Stopping auth domain.")
+	m.logger.Info("Stopping seeder domain.")
 	return nil
 }
 
 func (d *Domain) logConfigurations() {
 	d.logger.Debug("----- Seeder Configuration -----")
-
+	d.logger.Debug("Enabled", zap.Bool("enabled", d.config.Enabled))
+	d.logger.Debug("Profile", zap.String("profile", d.config.Profile))
+	d.logger.Debug("Required", zap.Bool("required", d.config.Required))
+	d.logger.Debug("DefaultPassword", zap.String("default_password", config.Redact(d.config.DefaultPassword)))
+	d.logger.Debug("FixturesPath", zap.String("fixtures_path", d.config.FixturesPath))
+	d.logger.Debug("Tenant", zap.String("tenant", d.config.Tenant))
+	d.logger.Debug("GeneratorSeed", zap.Int64("generator.seed", d.config.Generator.Seed))
+	d.logger.Debug("GeneratorUsers", zap.Int("generator.users", d.config.Generator.Users))
+	d.logger.Debug("GeneratorContentPerUser", zap.Int("generator.content_per_user", d.config.Generator.ContentPerUser))
 	d.logger.Debug("-------------------------------")
 }
@@ -0,0 +1,661 @@
+package seeder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"funcedup/internal/auth"
+	"funcedup/internal/schema"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// -------------------------------------------------------------------------
+// Fixture files describe seed data declaratively. Entries reference each other
+// by symbolic keys instead of IDs, e.g.
+//
+//	users:
+//	  michael: {username: michael, email: michael.chen@elmntri.com}
+//	tags:
+//	  fuel: {name: fuel}
+//	content:
+//	  michael-1: {owner: michael, title: "Michael's Content 1", body: "...", tags: [fuel]}
+//	discussions:
+//	  michael-1: {owner: michael, content: michael-1}
+//	replies:
+//	  first: {owner: michael, discussion: michael-1, body: "..."}
+//	  second: {owner: michael, discussion: michael-1, parent: first, body: "..."}
+//
+// Sections may be split over several .yaml, .yml or .json files, keys must be
+// unique per section across all files.
+// -------------------------------------------------------------------------
+
+// fixture sections in dependency order
+const (
+	sectionUsers       = "users"
+	sectionTags        = "tags"
+	sectionContent     = "content"
+	sectionContentTags = "content_tags"
+	sectionDiscussions = "discussions"
+	sectionNotes       = "notes"
+	sectionReplies     = "replies"
+)
+
+// location of an entry, used in error messages
+type fixtureSource struct {
+	File string
+	Line int
+}
+
+func (s fixtureSource) String() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+type UserFixture struct {
+	Username string `yaml:"username"`
+	Email    string `yaml:"email"`
+	Password string `yaml:"password"` // defaults to seeder.default_password
+	Role     string `yaml:"role"`
+	Points   int    `yaml:"points"`
+
+	key    string
+	source fixtureSource
+}
+
+type TagFixture struct {
+	Name string `yaml:"name"`
+
+	key    string
+	source fixtureSource
+}
+
+type ContentFixture struct {
+	Owner string   `yaml:"owner"`
+	Title string   `yaml:"title"`
+	Body  string   `yaml:"body"`
+	Tags  []string `yaml:"tags"`
+
+	key    string
+	source fixtureSource
+}
+
+type ContentTagFixture struct {
+	Content      string `yaml:"content"`
+	Tag          string `yaml:"tag"`
+	Relationship string `yaml:"relationship"`
+
+	source fixtureSource
+}
+
+type DiscussionFixture struct {
+	Owner   string `yaml:"owner"`
+	Content string `yaml:"content"`
+
+	key    string
+	source fixtureSource
+}
+
+type NoteFixture struct {
+	Owner       string `yaml:"owner"`
+	Content     string `yaml:"content"`
+	Body        string `yaml:"body"`
+	Visibility  string `yaml:"visibility"`
+	AnchorStart *int   `yaml:"anchor_start"`
+	AnchorEnd   *int   `yaml:"anchor_end"`
+
+	key    string
+	source fixtureSource
+}
+
+// ReplyFixture is a reply to either a discussion or a note. Its text is either
+// a reference to a content entry or inline title/body.
+type ReplyFixture struct {
+	Owner      string `yaml:"owner"`
+	Discussion string `yaml:"discussion"`
+	Note       string `yaml:"note"`
+	Parent     string `yaml:"parent"` // discussion replies only
+	Content    string `yaml:"content"`
+	Title      string `yaml:"title"`
+	Body       string `yaml:"body"`
+
+	key    string
+	source fixtureSource
+}
+
+// Fixtures is the merged content of one or more fixture files.
+type Fixtures struct {
+	Users       []*UserFixture
+	Tags        []*TagFixture
+	Contents    []*ContentFixture
+	ContentTags []*ContentTagFixture
+	Discussions []*DiscussionFixture
+	Notes       []*NoteFixture
+	Replies     []*ReplyFixture
+
+	// key -> location of first definition, per section
+	keys map[string]map[string]fixtureSource
+}
+
+// FixtureError collects every problem found in the fixture files.
+type FixtureError struct {
+	Problems []string
+}
+
+func (e *FixtureError) Error() string {
+	return fmt.Sprintf("%d fixture problem(s):\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+func (e *FixtureError) add(source fixtureSource, format string, args ...interface{}) {
+	e.Problems = append(e.Problems, source.String()+": "+fmt.Sprintf(format, args...))
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// LoadFixtures reads fixture files and directories of fixture files, then
+// checks that every reference resolves. All problems are reported at once as
+// a *FixtureError.
+func LoadFixtures(paths ...string) (*Fixtures, error) {
+	files, err := fixtureFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixture files found in %s", strings.Join(paths, ", "))
+	}
+
+	f := &Fixtures{keys: make(map[string]map[string]fixtureSource)}
+	problems := &FixtureError{}
+
+	for _, file := range files {
+		if err := f.parseFile(file, problems); err != nil {
+			return nil, err
+		}
+	}
+
+	f.validate(problems)
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
+
+	return f, nil
+}
+
+// SeedFixtures loads fixtures from paths and inserts them in a single
+// transaction. Existing rows are reused, so fixtures can be applied repeatedly.
+func (d *Domain) SeedFixtures(paths ...string) error {
+	return d.transaction(func(db *gorm.DB) error {
+		return d.seedFixtures(db, paths...)
+	})
+}
+
+func (d *Domain) seedFixtures(db *gorm.DB, paths ...string) error {
+	fixtures, err := LoadFixtures(paths...)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := auth.HashPassword(d.config.DefaultPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash seed password: %w", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return fixtures.apply(tx, passwordHash)
+	})
+	if err != nil {
+		return err
+	}
+
+	d.logger.Info("Fixtures seeded.",
+		zap.Strings("paths", paths),
+		zap.Int("users", len(fixtures.Users)),
+		zap.Int("content", len(fixtures.Contents)),
+		zap.Int("replies", len(fixtures.Replies)),
+	)
+	return nil
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func fixtureFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseFile decodes one file. JSON is valid YAML, so both go through the YAML
+// node API which keeps line numbers.
+func (f *Fixtures) parseFile(file string, problems *FixtureError) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		problems.add(fixtureSource{file, root.Line}, "expected a mapping of sections")
+		return nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section, body := root.Content[i], root.Content[i+1]
+		source := fixtureSource{file, section.Line}
+
+		switch section.Value {
+		case sectionContentTags:
+			if body.Kind != yaml.SequenceNode {
+				problems.add(source, "%s must be a list", section.Value)
+				continue
+			}
+			for _, item := range body.Content {
+				entry := &ContentTagFixture{source: fixtureSource{file, item.Line}}
+				if err := item.Decode(entry); err != nil {
+					problems.add(entry.source, "%v", err)
+					continue
+				}
+				f.ContentTags = append(f.ContentTags, entry)
+			}
+		case sectionUsers, sectionTags, sectionContent, sectionDiscussions, sectionNotes, sectionReplies:
+			if body.Kind != yaml.MappingNode {
+				problems.add(source, "%s must be a mapping of key to entry", section.Value)
+				continue
+			}
+			for j := 0; j+1 < len(body.Content); j += 2 {
+				f.parseEntry(section.Value, body.Content[j], body.Content[j+1], file, problems)
+			}
+		default:
+			problems.add(source, "unknown section %q", section.Value)
+		}
+	}
+
+	return nil
+}
+
+func (f *Fixtures) parseEntry(section string, keyNode *yaml.Node, value *yaml.Node, file string, problems *FixtureError) {
+	key := keyNode.Value
+	source := fixtureSource{file, keyNode.Line}
+
+	if f.keys[section] == nil {
+		f.keys[section] = make(map[string]fixtureSource)
+	}
+	if previous, ok := f.keys[section][key]; ok {
+		problems.add(source, "%s key %q is already defined at %s", section, key, previous)
+		return
+	}
+	f.keys[section][key] = source
+
+	var err error
+	switch section {
+	case sectionUsers:
+		entry := &UserFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			f.Users = append(f.Users, entry)
+		}
+	case sectionTags:
+		entry := &TagFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			if entry.Name == "" {
+				entry.Name = key
+			}
+			f.Tags = append(f.Tags, entry)
+		}
+	case sectionContent:
+		entry := &ContentFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			f.Contents = append(f.Contents, entry)
+		}
+	case sectionDiscussions:
+		entry := &DiscussionFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			f.Discussions = append(f.Discussions, entry)
+		}
+	case sectionNotes:
+		entry := &NoteFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			f.Notes = append(f.Notes, entry)
+		}
+	case sectionReplies:
+		entry := &ReplyFixture{key: key, source: source}
+		if err = value.Decode(entry); err == nil {
+			f.Replies = append(f.Replies, entry)
+		}
+	}
+	if err != nil {
+		problems.add(source, "%s %q: %v", section, key, err)
+	}
+}
+
+// ref reports a problem when key is not defined in section.
+func (f *Fixtures) ref(problems *FixtureError, source fixtureSource, from string, section string, key string) {
+	if key == "" {
+		problems.add(source, "%s is missing a %s reference", from, strings.TrimSuffix(section, "s"))
+		return
+	}
+	if _, ok := f.keys[section][key]; !ok {
+		problems.add(source, "%s references unknown %s %q", from, strings.TrimSuffix(section, "s"), key)
+	}
+}
+
+func (f *Fixtures) validate(problems *FixtureError) {
+	for _, u := range f.Users {
+		if u.Username == "" || u.Email == "" {
+			problems.add(u.source, "user %q needs a username and an email", u.key)
+		}
+	}
+	for _, c := range f.Contents {
+		from := fmt.Sprintf("content %q", c.key)
+		f.ref(problems, c.source, from, sectionUsers, c.Owner)
+		for _, tag := range c.Tags {
+			f.ref(problems, c.source, from, sectionTags, tag)
+		}
+	}
+	for _, ct := range f.ContentTags {
+		f.ref(problems, ct.source, "content tag", sectionContent, ct.Content)
+		f.ref(problems, ct.source, "content tag", sectionTags, ct.Tag)
+	}
+	for _, d := range f.Discussions {
+		from := fmt.Sprintf("discussion %q", d.key)
+		f.ref(problems, d.source, from, sectionUsers, d.Owner)
+		f.ref(problems, d.source, from, sectionContent, d.Content)
+	}
+	for _, n := range f.Notes {
+		from := fmt.Sprintf("note %q", n.key)
+		f.ref(problems, n.source, from, sectionUsers, n.Owner)
+		f.ref(problems, n.source, from, sectionContent, n.Content)
+		switch n.Visibility {
+		case "", schema.NoteVisibilityPrivate, schema.NoteVisibilityFollowers, schema.NoteVisibilityPublic:
+		default:
+			problems.add(n.source, "%s has unknown visibility %q", from, n.Visibility)
+		}
+		if (n.AnchorStart == nil) != (n.AnchorEnd == nil) {
+			problems.add(n.source, "%s needs both anchor_start and anchor_end", from)
+		}
+	}
+
+	replies := make(map[string]*ReplyFixture, len(f.Replies))
+	for _, r := range f.Replies {
+		replies[r.key] = r
+	}
+	for _, r := range f.Replies {
+		from := fmt.Sprintf("reply %q", r.key)
+		f.ref(problems, r.source, from, sectionUsers, r.Owner)
+
+		switch {
+		case r.Discussion != "" && r.Note != "":
+			problems.add(r.source, "%s must reply to either a discussion or a note, not both", from)
+		case r.Discussion != "":
+			f.ref(problems, r.source, from, sectionDiscussions, r.Discussion)
+		case r.Note != "":
+			f.ref(problems, r.source, from, sectionNotes, r.Note)
+		default:
+			problems.add(r.source, "%s needs a discussion or a note", from)
+		}
+
+		if r.Content != "" && r.Body != "" {
+			problems.add(r.source, "%s must use either a content reference or an inline body", from)
+		} else if r.Content != "" {
+			f.ref(problems, r.source, from, sectionContent, r.Content)
+		} else if r.Body == "" {
+			problems.add(r.source, "%s needs a content reference or an inline body", from)
+		}
+
+		if r.Parent == "" {
+			continue
+		}
+		parent, ok := replies[r.Parent]
+		switch {
+		case !ok:
+			problems.add(r.source, "%s references unknown parent reply %q", from, r.Parent)
+		case r.Note != "":
+			problems.add(r.source, "%s: note replies cannot have a parent", from)
+		case parent.Discussion != r.Discussion:
+			problems.add(r.source, "%s and its parent %q belong to different discussions", from, r.Parent)
+		}
+	}
+
+	if _, err := f.sortedReplies(); err != nil {
+		problems.Problems = append(problems.Problems, err.Error())
+	}
+}
+
+// sortedReplies orders replies so that parents come before their children.
+func (f *Fixtures) sortedReplies() ([]*ReplyFixture, error) {
+	byKey := make(map[string]*ReplyFixture, len(f.Replies))
+	for _, r := range f.Replies {
+		byKey[r.key] = r
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(f.Replies))
+	sorted := make([]*ReplyFixture, 0, len(f.Replies))
+
+	var visit func(r *ReplyFixture) error
+	visit = func(r *ReplyFixture) error {
+		switch state[r.key] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%s: reply %q is part of a parent cycle", r.source, r.key)
+		}
+		state[r.key] = visiting
+		if parent, ok := byKey[r.Parent]; ok {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[r.key] = done
+		sorted = append(sorted, r)
+		return nil
+	}
+
+	for _, r := range f.Replies {
+		if err := visit(r); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// apply inserts the fixtures in dependency order.
+func (f *Fixtures) apply(tx *gorm.DB, defaultPasswordHash string) error {
+	users := make(map[string]uuid.UUID)
+	tags := make(map[string]uuid.UUID)
+	contents := make(map[string]uuid.UUID)
+	discussions := make(map[string]uuid.UUID)
+	notes := make(map[string]uuid.UUID)
+	replies := make(map[string]uuid.UUID)
+
+	wrap := func(source fixtureSource, err error) error {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	for _, u := range f.Users {
+		hash := defaultPasswordHash
+		if u.Password != "" {
+			var err error
+			if hash, err = auth.HashPassword(u.Password); err != nil {
+				return wrap(u.source, err)
+			}
+		}
+		user := schema.User{
+			Username:     strings.ToLower(u.Username),
+			Email:        strings.ToLower(u.Email),
+			PasswordHash: hash,
+			Role:         u.Role,
+			Points:       u.Points,
+		}
+		if user.Role == "" {
+			user.Role = schema.UserRoleMember
+		}
+		if err := tx.Where("email = ?", user.Email).FirstOrCreate(&user).Error; err != nil {
+			return wrap(u.source, err)
+		}
+		users[u.key] = user.ID
+	}
+
+	for _, t := range f.Tags {
+		tag := schema.Tag{Name: t.Name}
+		if err := tx.Where("name = ?", tag.Name).FirstOrCreate(&tag).Error; err != nil {
+			return wrap(t.source, err)
+		}
+		tags[t.key] = tag.ID
+	}
+
+	attachTag := func(source fixtureSource, contentID uuid.UUID, tagID uuid.UUID, relationship string) error {
+		contentTag := schema.ContentTag{ContentID: contentID, TagID: tagID, Relationship: relationship}
+		err := tx.
+			Where("content_id = ? AND tag_id = ?", contentID, tagID).
+			FirstOrCreate(&contentTag).
+			Error
+		if err != nil {
+			return wrap(source, err)
+		}
+		return nil
+	}
+
+	for _, c := range f.Contents {
+		content := schema.Content{OwnerID: users[c.Owner], Title: c.Title, Body: c.Body}
+		if err := tx.Where("owner_id = ? AND title = ?", content.OwnerID, content.Title).FirstOrCreate(&content).Error; err != nil {
+			return wrap(c.source, err)
+		}
+		contents[c.key] = content.ID
+
+		for _, tag := range c.Tags {
+			if err := attachTag(c.source, content.ID, tags[tag], ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, ct := range f.ContentTags {
+		if err := attachTag(ct.source, contents[ct.Content], tags[ct.Tag], ct.Relationship); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range f.Discussions {
+		discussion := schema.Discussion{OwnerID: users[d.Owner], ContentID: contents[d.Content]}
+		err := tx.
+			Where("owner_id = ? AND content_id = ?", discussion.OwnerID, discussion.ContentID).
+			FirstOrCreate(&discussion).
+			Error
+		if err != nil {
+			return wrap(d.source, err)
+		}
+		discussions[d.key] = discussion.ID
+	}
+
+	for _, n := range f.Notes {
+		note := schema.Note{
+			OwnerID:     users[n.Owner],
+			ContentID:   contents[n.Content],
+			Body:        n.Body,
+			Visibility:  n.Visibility,
+			AnchorStart: n.AnchorStart,
+			AnchorEnd:   n.AnchorEnd,
+		}
+		if note.Visibility == "" {
+			note.Visibility = schema.NoteVisibilityPrivate
+		}
+		err := tx.
+			Where("owner_id = ? AND content_id = ? AND body = ?", note.OwnerID, note.ContentID, note.Body).
+			FirstOrCreate(&note).
+			Error
+		if err != nil {
+			return wrap(n.source, err)
+		}
+		notes[n.key] = note.ID
+	}
+
+	sorted, err := f.sortedReplies()
+	if err != nil {
+		return err
+	}
+	for _, r := range sorted {
+		ownerID := users[r.Owner]
+
+		contentID, ok := contents[r.Content]
+		if !ok {
+			content := schema.Content{OwnerID: ownerID, Title: r.Title, Body: r.Body}
+			err := tx.
+				Where("owner_id = ? AND title = ? AND body = ?", content.OwnerID, content.Title, content.Body).
+				FirstOrCreate(&content).
+				Error
+			if err != nil {
+				return wrap(r.source, err)
+			}
+			contentID = content.ID
+		}
+
+		if r.Note != "" {
+			reply := schema.NoteReply{OwnerID: ownerID, NoteID: notes[r.Note], ContentID: contentID}
+			err := tx.
+				Where("owner_id = ? AND note_id = ? AND content_id = ?", reply.OwnerID, reply.NoteID, reply.ContentID).
+				FirstOrCreate(&reply).
+				Error
+			if err != nil {
+				return wrap(r.source, err)
+			}
+			replies[r.key] = reply.ID
+			continue
+		}
+
+		reply := schema.DiscusionReply{OwnerID: ownerID, DiscussionID: discussions[r.Discussion], ContentID: contentID}
+		if r.Parent != "" {
+			parentID := replies[r.Parent]
+			reply.ParentID = &parentID
+		}
+		err := tx.
+			Where("owner_id = ? AND discussion_id = ? AND content_id = ?", reply.OwnerID, reply.DiscussionID, reply.ContentID).
+			FirstOrCreate(&reply).
+			Error
+		if err != nil {
+			return wrap(r.source, err)
+		}
+		replies[r.key] = reply.ID
+	}
+
+	return nil
+}
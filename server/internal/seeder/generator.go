@@ -0,0 +1,467 @@
+package seeder
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"funcedup/internal/auth"
+	"funcedup/internal/schema"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GeneratorConfig controls the size and shape of generated data.
+// Per-item counts are averages, the actual number varies per item.
+type GeneratorConfig struct {
+	Seed                 int64 `mapstructure:"seed"`
+	Users                int   `mapstructure:"users" validate:"gte=0"`
+	Tags                 int   `mapstructure:"tags" validate:"gte=0"`
+	ContentPerUser       int   `mapstructure:"content_per_user" validate:"gte=0"`
+	TagsPerContent       int   `mapstructure:"tags_per_content" validate:"gte=0"`
+	RepliesPerDiscussion int   `mapstructure:"replies_per_discussion" validate:"gte=0"`
+	MaxReplyDepth        int   `mapstructure:"max_reply_depth" validate:"gte=0"`
+	NotesPerContent      int   `mapstructure:"notes_per_content" validate:"gte=0"`
+	FollowsPerUser       int   `mapstructure:"follows_per_user" validate:"gte=0"`
+	BatchSize            int   `mapstructure:"batch_size" validate:"min=1"`
+}
+
+const (
+	DefaultGeneratorSeed                 = 1
+	DefaultGeneratorUsers                = 100
+	DefaultGeneratorTags                 = 20
+	DefaultGeneratorContentPerUser       = 10
+	DefaultGeneratorTagsPerContent       = 3
+	DefaultGeneratorRepliesPerDiscussion = 8
+	DefaultGeneratorMaxReplyDepth        = 8
+	DefaultGeneratorNotesPerContent      = 2
+	DefaultGeneratorFollowsPerUser       = 10
+	DefaultGeneratorBatchSize            = 500
+)
+
+// all generated timestamps fall within a year of this date
+var generatorEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// generated holds every row of a generator run, in insert order.
+type generated struct {
+	Users       []schema.User
+	Tags        []schema.Tag
+	Contents    []schema.Content
+	ContentTags []schema.ContentTag
+	Discussions []schema.Discussion
+	Replies     []schema.DiscusionReply
+	Notes       []schema.Note
+	NoteReplies []schema.NoteReply
+	Follows     []schema.Follow
+}
+
+// generator produces rows from a single random source. Rows, including their
+// IDs and timestamps, only depend on the config, so the same seed always
+// produces the same data.
+type generator struct {
+	config GeneratorConfig
+	rng    *rand.Rand
+	data   generated
+
+	// top level content, replies are content rows too but stay untagged
+	posts []schema.Content
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// GeneratorConfig returns the generator settings from seeder.generator.*.
+func (d *Domain) GeneratorConfig() GeneratorConfig {
+	return d.config.Generator
+}
+
+// Generate inserts synthetic users, tagged content, discussions with reply
+// trees, notes and follows. Rows are inserted in batches of cfg.BatchSize
+// and existing rows are skipped, so running the same seed twice is a no-op.
+// Usernames carry the seed, different seeds add separate sets of users.
+func (d *Domain) Generate(cfg GeneratorConfig) error {
+	return d.transaction(func(db *gorm.DB) error {
+		return d.generate(db, cfg)
+	})
+}
+
+// generate writes the generated rows in a transaction nested in db, see Generate.
+func (d *Domain) generate(db *gorm.DB, cfg GeneratorConfig) error {
+	if cfg.Users < 1 {
+		return fmt.Errorf("generator needs at least one user, got %d", cfg.Users)
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = DefaultGeneratorBatchSize
+	}
+
+	passwordHash, err := auth.HashPassword(d.config.DefaultPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash seed password: %w", err)
+	}
+
+	started := time.Now()
+	d.logger.Info("Generating data.",
+		zap.Int64("seed", cfg.Seed),
+		zap.Int("users", cfg.Users),
+		zap.Int("content_per_user", cfg.ContentPerUser),
+	)
+
+	g := &generator{config: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+	g.generate(passwordHash)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// tags are shared by name with other profiles and fixtures
+		for i := range g.data.Tags {
+			err := tx.Where("name = ?", g.data.Tags[i].Name).FirstOrCreate(&g.data.Tags[i]).Error
+			if err != nil {
+				return fmt.Errorf("failed to seed tag %s: %w", g.data.Tags[i].Name, err)
+			}
+		}
+		g.linkTags()
+
+		steps := []func() error{
+			func() error { return insertBatches(d, tx, "users", g.data.Users, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "content", g.data.Contents, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "content tags", g.data.ContentTags, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "discussions", g.data.Discussions, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "discussion replies", g.data.Replies, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "notes", g.data.Notes, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "note replies", g.data.NoteReplies, cfg.BatchSize) },
+			func() error { return insertBatches(d, tx, "follows", g.data.Follows, cfg.BatchSize) },
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.logger.Info("Generated data seeded.",
+		zap.Int64("seed", cfg.Seed),
+		zap.Int("users", len(g.data.Users)),
+		zap.Int("content", len(g.data.Contents)),
+		zap.Int("discussion_replies", len(g.data.Replies)),
+		zap.Int("notes", len(g.data.Notes)),
+		zap.Int("note_replies", len(g.data.NoteReplies)),
+		zap.Int("follows", len(g.data.Follows)),
+		zap.Duration("took", time.Since(started)),
+	)
+	return nil
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// insertBatches inserts rows batchSize at a time, skipping rows that already
+// exist, and logs progress roughly every tenth of the way.
+func insertBatches[T any](d *Domain, tx *gorm.DB, name string, rows []T, batchSize int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	logEvery := max(len(rows)/10, batchSize)
+	logged := 0
+	for start := 0; start < len(rows); start += batchSize {
+		end := min(start+batchSize, len(rows))
+		batch := rows[start:end]
+
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch).Error
+		if err != nil {
+			return fmt.Errorf("failed to insert %s %d-%d: %w", name, start, end, err)
+		}
+
+		if end-logged >= logEvery || end == len(rows) {
+			d.logger.Info("Inserting generated rows.", zap.String("table", name), zap.Int("done", end), zap.Int("total", len(rows)))
+			logged = end
+		}
+	}
+	return nil
+}
+
+func (g *generator) generate(passwordHash string) {
+	g.generateTags()
+	g.generateUsers(passwordHash)
+	g.generateFollows()
+
+	for _, owner := range g.data.Users {
+		for range g.around(g.config.ContentPerUser) {
+			content := g.newContent(owner.ID, g.title(), g.markdown(2+g.rng.Intn(5)))
+			g.posts = append(g.posts, content)
+			g.generateDiscussion(content)
+			g.generateNotes(content)
+		}
+	}
+}
+
+func (g *generator) generateTags() {
+	for i := 0; i < g.config.Tags; i++ {
+		name := tagWords[i%len(tagWords)]
+		if i >= len(tagWords) {
+			name = fmt.Sprintf("%s-%d", name, i/len(tagWords)+1)
+		}
+		g.data.Tags = append(g.data.Tags, schema.Tag{Name: name})
+	}
+}
+
+func (g *generator) generateUsers(passwordHash string) {
+	for i := 1; i <= g.config.Users; i++ {
+		first := g.pick(firstNames)
+		last := g.pick(lastNames)
+		username := fmt.Sprintf("%s.%s.s%d.%d", first, last, g.config.Seed, i)
+
+		g.data.Users = append(g.data.Users, schema.User{
+			TenantBaseModel: g.base(),
+			Username:        username,
+			Email:           username + "@funcedup.test",
+			PasswordHash:    passwordHash,
+			Points:          g.rng.Intn(1000),
+			Role:            schema.UserRoleMember,
+		})
+	}
+}
+
+func (g *generator) generateFollows() {
+	users := g.data.Users
+	for _, follower := range users {
+		followees := make(map[uuid.UUID]bool)
+		for range g.around(g.config.FollowsPerUser) {
+			followee := users[g.rng.Intn(len(users))]
+			if followee.ID == follower.ID || followees[followee.ID] {
+				continue
+			}
+			followees[followee.ID] = true
+			g.data.Follows = append(g.data.Follows, schema.Follow{
+				TenantBaseModel: g.base(),
+				FollowerID:      follower.ID,
+				FolloweeID:      followee.ID,
+			})
+		}
+	}
+}
+
+// generateDiscussion adds a discussion to content with a reply tree. Replies
+// favour the latest reply as parent, which produces long chains up to
+// MaxReplyDepth next to broad top level threads.
+func (g *generator) generateDiscussion(content schema.Content) {
+	discussion := schema.Discussion{TenantBaseModel: g.base(), OwnerID: content.OwnerID, ContentID: content.ID}
+	discussion.CreatedAt = content.CreatedAt
+	g.data.Discussions = append(g.data.Discussions, discussion)
+
+	var replies []schema.DiscusionReply
+	var depths []int
+	for range g.around(g.config.RepliesPerDiscussion) {
+		var parent *uuid.UUID
+		depth := 1
+
+		if n := len(replies); n > 0 {
+			candidate := -1
+			switch roll := g.rng.Intn(10); {
+			case roll < 5:
+				candidate = n - 1
+			case roll < 8:
+				candidate = g.rng.Intn(n)
+			}
+			if candidate >= 0 && depths[candidate] < g.config.MaxReplyDepth {
+				parent = &replies[candidate].ID
+				depth = depths[candidate] + 1
+			}
+		}
+
+		owner := pickFrom(g.rng, g.data.Users)
+		body := g.newContent(owner.ID, "Re: "+content.Title, g.markdown(1+g.rng.Intn(2)))
+		replies = append(replies, schema.DiscusionReply{
+			TenantBaseModel: g.base(),
+			OwnerID:         owner.ID,
+			DiscussionID:    discussion.ID,
+			ContentID:       body.ID,
+			ParentID:        parent,
+		})
+		depths = append(depths, depth)
+	}
+
+	g.data.Replies = append(g.data.Replies, replies...)
+}
+
+// generateNotes adds notes to content, half of them anchored to a passage of
+// the body. Notes others can read get a few replies.
+func (g *generator) generateNotes(content schema.Content) {
+	visibilities := []string{schema.NoteVisibilityPrivate, schema.NoteVisibilityFollowers, schema.NoteVisibilityPublic}
+	body := []rune(content.Body)
+
+	for range g.around(g.config.NotesPerContent) {
+		owner := pickFrom(g.rng, g.data.Users)
+		note := schema.Note{
+			TenantBaseModel: g.base(),
+			OwnerID:         owner.ID,
+			ContentID:       content.ID,
+			Body:            g.sentence(),
+			Visibility:      g.pick(visibilities),
+		}
+
+		if g.rng.Intn(2) == 0 && len(body) > 1 {
+			start := g.rng.Intn(len(body) - 1)
+			end := min(start+1+g.rng.Intn(80), len(body))
+			note.AnchorStart = &start
+			note.AnchorEnd = &end
+			note.Quote = string(body[start:end])
+		}
+		g.data.Notes = append(g.data.Notes, note)
+
+		if note.Visibility == schema.NoteVisibilityPrivate {
+			continue
+		}
+		for range g.rng.Intn(3) {
+			replier := pickFrom(g.rng, g.data.Users)
+			reply := g.newContent(replier.ID, "Re: note on "+content.Title, g.sentence())
+			g.data.NoteReplies = append(g.data.NoteReplies, schema.NoteReply{
+				TenantBaseModel: g.base(),
+				OwnerID:         replier.ID,
+				NoteID:          note.ID,
+				ContentID:       reply.ID,
+			})
+		}
+	}
+}
+
+// linkTags tags every content row, which needs the tag IDs from the database.
+func (g *generator) linkTags() {
+	if len(g.data.Tags) == 0 {
+		return
+	}
+
+	// a separate source keeps the other rows independent of the tag count
+	rng := rand.New(rand.NewSource(g.config.Seed))
+	for _, content := range g.posts {
+		count := min(1+rng.Intn(max(g.config.TagsPerContent, 1)), len(g.data.Tags))
+		for _, i := range rng.Perm(len(g.data.Tags))[:count] {
+			id := uuid.Must(uuid.NewRandomFromReader(rng))
+			g.data.ContentTags = append(g.data.ContentTags, schema.ContentTag{
+				TenantBaseModel: schema.TenantBaseModel{
+					BaseModel: schema.BaseModel{ID: id, CreatedAt: content.CreatedAt, UpdatedAt: content.CreatedAt},
+				},
+				ContentID: content.ID,
+				TagID:     g.data.Tags[i].ID,
+			})
+		}
+	}
+}
+
+func (g *generator) newContent(ownerID uuid.UUID, title string, body string) schema.Content {
+	content := schema.Content{TenantBaseModel: g.base(), OwnerID: ownerID, Title: title, Body: body}
+	g.data.Contents = append(g.data.Contents, content)
+	return content
+}
+
+// base returns a TenantBaseModel with an ID and timestamp drawn from the
+// generator, the tenant is set on insert.
+func (g *generator) base() schema.TenantBaseModel {
+	createdAt := generatorEpoch.Add(time.Duration(g.rng.Int63n(int64(365 * 24 * time.Hour))))
+	return schema.TenantBaseModel{
+		BaseModel: schema.BaseModel{
+			ID:        uuid.Must(uuid.NewRandomFromReader(g.rng)),
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		},
+	}
+}
+
+// around returns a count between 0 and twice the average.
+func (g *generator) around(average int) int {
+	if average <= 0 {
+		return 0
+	}
+	return g.rng.Intn(2*average + 1)
+}
+
+func pickFrom[T any](rng *rand.Rand, items []T) T {
+	return items[rng.Intn(len(items))]
+}
+
+func (g *generator) pick(items []string) string {
+	return pickFrom(g.rng, items)
+}
+
+func (g *generator) title() string {
+	words := make([]string, 3+g.rng.Intn(4))
+	for i := range words {
+		words[i] = g.pick(bodyWords)
+	}
+	words[0] = strings.ToUpper(words[0][:1]) + words[0][1:]
+	return strings.Join(words, " ")
+}
+
+func (g *generator) sentence() string {
+	words := make([]string, 6+g.rng.Intn(14))
+	for i := range words {
+		words[i] = g.pick(bodyWords)
+	}
+	words[0] = strings.ToUpper(words[0][:1]) + words[0][1:]
+	return strings.Join(words, " ") + "."
+}
+
+// markdown returns a body of the given number of blocks, mixing headings,
+// paragraphs, lists, quotes and code.
+func (g *generator) markdown(blocks int) string {
+	parts := make([]string, 0, blocks)
+	for i := 0; i < blocks; i++ {
+		switch g.rng.Intn(6) {
+		case 0:
+			parts = append(parts, "## "+g.title())
+		case 1:
+			items := make([]string, 2+g.rng.Intn(4))
+			for j := range items {
+				items[j] = "- " + g.sentence()
+			}
+			parts = append(parts, strings.Join(items, "\n"))
+		case 2:
+			parts = append(parts, "> "+g.sentence())
+		case 3:
+			parts = append(parts, fmt.Sprintf("```\n%s = %d\n```", g.pick(bodyWords), g.rng.Intn(1000)))
+		default:
+			sentences := make([]string, 2+g.rng.Intn(4))
+			for j := range sentences {
+				sentences[j] = g.sentence()
+			}
+			words := strings.Fields(sentences[0])
+			i := g.rng.Intn(len(words))
+			words[i] = "**" + strings.TrimSuffix(words[i], ".") + "**"
+			sentences[0] = strings.Join(words, " ")
+			if i == len(words)-1 {
+				sentences[0] += "."
+			}
+			parts = append(parts, strings.Join(sentences, " "))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+var firstNames = []string{
+	"ada", "alan", "barbara", "carl", "chen", "dana", "elena", "farid", "grace", "hiro",
+	"ines", "jeff", "kofi", "lena", "maria", "michael", "nadia", "omar", "priya", "quinn",
+	"rosa", "sam", "tariq", "uma", "vimal", "wei", "ximena", "yusuf", "zoe",
+}
+
+var lastNames = []string{
+	"alvarez", "brown", "chen", "davies", "evans", "fischer", "garcia", "hsu", "ito", "jensen",
+	"kim", "lopez", "mensah", "nguyen", "okafor", "patel", "rossi", "silva", "tanaka", "walker",
+}
+
+var tagWords = []string{
+	"charge", "clock", "fuel", "methylation", "oxidation", "reduction", "autophagy", "insulin",
+	"ketosis", "mitochondria", "circadian", "fasting", "glycolysis", "sirtuins", "nad",
+	"inflammation", "microbiome", "sleep", "exercise", "longevity",
+}
+
+var bodyWords = []string{
+	"energy", "cell", "signal", "pathway", "enzyme", "protein", "glucose", "fat", "rhythm",
+	"repair", "stress", "balance", "membrane", "electron", "gradient", "hormone", "response",
+	"cycle", "rate", "load", "supply", "demand", "storage", "release", "threshold", "window",
+	"the", "a", "of", "and", "in", "with", "under", "during", "after", "before", "drives",
+	"limits", "shifts", "restores", "depends", "on", "slowly", "quickly", "often", "rarely",
+}
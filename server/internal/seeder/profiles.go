@@ -0,0 +1,132 @@
+package seeder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/config"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Named sets of seed data.
+const (
+	ProfileMinimal  = "minimal"   // users and tags, enough to sign in
+	ProfileDemo     = "demo"      // minimal plus content, discussions and notes
+	ProfileLoadTest = "load-test" // demo plus data from the generator, see seeder.generator
+	ProfileFixtures = "fixtures"  // minimal plus the files in seeder.fixtures_path
+)
+
+const productionEnvironment = "production"
+
+var ErrProductionDatabase = errors.New("refusing to seed a production database")
+
+// seedStep is a single named seeding function of a profile.
+type seedStep struct {
+	name string
+	run  func(db *gorm.DB, seedIDs *SeedIDs) error
+}
+
+// -------------------------------------------------------------------------
+// SeedProfile runs all seed functions of the profile in a proper sequence,
+// passing around a single SeedIDs struct to store/retrieve IDs as they’re
+// created or loaded. Every step uses FirstOrCreate so profiles are idempotent.
+// All steps run in one transaction, a failed step leaves nothing behind.
+// -------------------------------------------------------------------------
+func (d *Domain) SeedProfile(profile string) error {
+	steps, err := d.profileSteps(profile)
+	if err != nil {
+		return err
+	}
+
+	d.logger.Info("Seeding profile.", zap.String("profile", profile))
+
+	seedIDs := newSeedIDs()
+	err = d.transaction(func(db *gorm.DB) error {
+		for _, step := range steps {
+			if err := step.run(db, seedIDs); err != nil {
+				d.logger.Error("Seeding step failed", zap.String("profile", profile), zap.String("step", step.name), zap.Error(err))
+				return fmt.Errorf("seed profile %s, step %s: %w", profile, step.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.logger.Info("Seeding completed.", zap.String("profile", profile))
+	return nil
+}
+
+// Profiles lists the names of all known profiles.
+func Profiles() []string {
+	profiles := []string{ProfileMinimal, ProfileDemo, ProfileLoadTest, ProfileFixtures}
+	sort.Strings(profiles)
+	return profiles
+}
+
+// GuardProduction returns ErrProductionDatabase when the server runs in
+// production, see config.IsProduction, or the database carries the production environment flag.
+// A database is flagged with:
+//
+//	INSERT INTO app_metadata (key, value) VALUES ('environment', 'production');
+func (d *Domain) GuardProduction(ctx context.Context) error {
+	if config.IsProduction() {
+		return fmt.Errorf("%w: build_env is %s or profile is %s", ErrProductionDatabase, config.ProductionEnvironment, config.ProfileProd)
+	}
+
+	db := d.params.DB.GetDB().WithContext(ctx)
+	if !db.Migrator().HasTable(&schema.AppMetadata{}) {
+		return nil
+	}
+
+	var metadata schema.AppMetadata
+	err := db.Where("key = ?", schema.AppMetadataEnvironment).Limit(1).Find(&metadata).Error
+	if err != nil {
+		return fmt.Errorf("failed to read database environment: %w", err)
+	}
+	if strings.EqualFold(metadata.Value, productionEnvironment) {
+		return fmt.Errorf("%w: database is flagged as %s", ErrProductionDatabase, productionEnvironment)
+	}
+
+	return nil
+}
+
+func (d *Domain) profileSteps(profile string) ([]seedStep, error) {
+	minimal := []seedStep{
+		{"users", d.seedUsers},
+		{"rehash users", func(db *gorm.DB, _ *SeedIDs) error { return d.rehashUsers(db) }},
+		{"tags", d.seedTags},
+	}
+	demo := append(minimal,
+		seedStep{"content", d.seedContent},
+		seedStep{"content tags", d.seedContentTags},
+		seedStep{"discussions", d.seedDiscussions},
+		seedStep{"notes", d.seedNotes},
+		seedStep{"discussion replies", d.seedDiscussionReplies},
+		seedStep{"note replies", d.seedNoteReplies},
+	)
+
+	switch profile {
+	case ProfileMinimal:
+		return minimal, nil
+	case ProfileDemo:
+		return demo, nil
+	case ProfileLoadTest:
+		return append(demo, seedStep{"generated data", func(db *gorm.DB, _ *SeedIDs) error {
+			return d.generate(db, d.config.Generator)
+		}}), nil
+	case ProfileFixtures:
+		return append(minimal, seedStep{"fixtures", func(db *gorm.DB, _ *SeedIDs) error {
+			return d.seedFixtures(db, d.config.FixturesPath)
+		}}), nil
+	default:
+		return nil, fmt.Errorf("unknown seed profile %q, expected one of %s", profile, strings.Join(Profiles(), ", "))
+	}
+}
@@ -1,12 +1,18 @@
 package seeder
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
+	"funcedup/internal/auth"
 	"funcedup/internal/schema"
+	"funcedup/internal/tenant"
+	"funcedup/pkg/pgconn"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // -------------------------------------------------------------------------
@@ -21,55 +27,67 @@ type SeedIDs struct {
 	Tags        map[string]uuid.UUID // Key = tag name,        Value = tag ID
 }
 
-// -------------------------------------------------------------------------
-// SeedAll runs all seed functions in a proper sequence, passing around a
-// single SeedIDs struct to store/retrieve IDs as they’re created or loaded.
-// -------------------------------------------------------------------------
-func (d *Domain) SeedAll() error {
-	seedIDs := &SeedIDs{
+func newSeedIDs() *SeedIDs {
+	return &SeedIDs{
 		Users:       make(map[string]uuid.UUID),
 		Contents:    make(map[string]uuid.UUID),
 		Discussions: make(map[string]uuid.UUID),
 		Notes:       make(map[string]uuid.UUID),
 		Tags:        make(map[string]uuid.UUID),
 	}
+}
 
-	// Run each seeding function in sequence
-	if err := d.seedUsers(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed users", zap.Error(err))
-		return err
-	}
-	if err := d.seedTags(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed tags", zap.Error(err))
-		return err
-	}
-	if err := d.seedContent(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed content", zap.Error(err))
-		return err
+// -------------------------------------------------------------------------
+// transaction runs fn in a transaction seed data is written with. Rows belong
+// to the seeder.tenant tenant when set and to no tenant otherwise. The
+// transaction carries the tenant, so row level security policies and the
+// search path of schema tenancy apply.
+// -------------------------------------------------------------------------
+func (d *Domain) transaction(fn func(db *gorm.DB) error) error {
+	ctx := pgconn.AllTenantsContext(context.Background())
+	if d.config.Tenant != "" {
+		t, err := tenant.FindTenant(d.params.DB.GetDB(), d.config.Tenant)
+		if err != nil {
+			return fmt.Errorf("seeder.tenant %s: %w", d.config.Tenant, err)
+		}
+		ctx = pgconn.WithTenant(context.Background(), pgconn.Tenant{ID: t.ID, Slug: t.Slug, Schema: t.Schema})
 	}
+	return d.params.DB.Transaction(ctx, fn)
+}
 
-	if err := d.seedContentTags(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to attach tags to content", zap.Error(err))
-		return err
+// -------------------------------------------------------------------------
+// Reset truncates every table the seeder writes to.
+// ! DESTRUCTIVE: removes all rows of every tenant, not only seeded ones.
+// -------------------------------------------------------------------------
+func (d *Domain) Reset() error {
+	models := []interface{}{
+		&schema.NoteReply{},
+		&schema.Note{},
+		&schema.DiscusionReply{},
+		&schema.Discussion{},
+		&schema.ContentTag{},
+		&schema.Tag{},
+		&schema.Content{},
+		&schema.Follow{},
+		&schema.User{},
 	}
 
-	if err := d.seedDiscussions(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed discussions", zap.Error(err))
-		return err
-	}
-	if err := d.seedNotes(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed notes", zap.Error(err))
-		return err
-	}
-	if err := d.seedDiscussionReplies(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed discussion replies", zap.Error(err))
-		return err
-	}
-	if err := d.seedNoteReplies(seedIDs); err != nil {
-		d.logger.Error("SeedAll: failed to seed note replies", zap.Error(err))
-		return err
+	tables := make([]string, 0, len(models))
+	err := d.transaction(func(db *gorm.DB) error {
+		for _, model := range models {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err != nil {
+				return fmt.Errorf("failed to resolve table name: %w", err)
+			}
+			tables = append(tables, db.Statement.Quote(stmt.Schema.Table))
+		}
+		return db.Exec("TRUNCATE TABLE " + strings.Join(tables, ", ") + " CASCADE").Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset tables: %w", err)
 	}
 
+	d.logger.Info("Seeded tables truncated.", zap.Strings("tables", tables))
 	return nil
 }
 
@@ -78,26 +96,29 @@ func (d *Domain) SeedAll() error {
 // -------------------------------------------------------------------------
 
 // seedUsers seeds user data and stores their IDs in seedIDs.
-func (d *Domain) seedUsers(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
+func (d *Domain) seedUsers(db *gorm.DB, seedIDs *SeedIDs) error {
+	passwordHash, err := auth.HashPassword(d.config.DefaultPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash seed password: %w", err)
+	}
 
 	users := []schema.User{
 		{
 			Username:     "michael",
 			Email:        "michael.chen@elmntri.com",
-			PasswordHash: "testtesttest",
+			PasswordHash: passwordHash,
 			Points:       0,
 		},
 		{
 			Username:     "alan",
 			Email:        "vimalan.renganattan@elmntri.com",
-			PasswordHash: "testtesttest",
+			PasswordHash: passwordHash,
 			Points:       0,
 		},
 		{
 			Username:     "jeff",
 			Email:        "jeff.hsu@elmntri.com",
-			PasswordHash: "testtesttest",
+			PasswordHash: passwordHash,
 			Points:       0,
 		},
 	}
@@ -115,10 +136,42 @@ func (d *Domain) seedUsers(seedIDs *SeedIDs) error {
 	return nil
 }
 
-// seedTags seeds some tags to be reused by Content records.
-func (d *Domain) seedTags(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
+// RehashUsers hashes passwords that were stored in plain text, e.g. by older
+// versions of the seeder, so those accounts can sign in with the same value.
+func (d *Domain) RehashUsers() error {
+	return d.transaction(d.rehashUsers)
+}
+
+func (d *Domain) rehashUsers(db *gorm.DB) error {
+	var users []schema.User
+	if err := db.Select("id", "password_hash").Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
 
+	for _, user := range users {
+		if user.PasswordHash == "" || auth.IsHashed(user.PasswordHash) {
+			continue
+		}
+
+		hash, err := auth.HashPassword(user.PasswordHash)
+		if err != nil {
+			return fmt.Errorf("failed to hash password for user %v: %w", user.ID, err)
+		}
+
+		err = db.Model(&schema.User{}).
+			Where("id = ?", user.ID).
+			Update("password_hash", hash).
+			Error
+		if err != nil {
+			return fmt.Errorf("failed to rehash password for user %v: %w", user.ID, err)
+		}
+		d.logger.Info("Rehashed plain text password", zap.String("user_id", user.ID.String()))
+	}
+	return nil
+}
+
+// seedTags seeds some tags to be reused by Content records.
+func (d *Domain) seedTags(db *gorm.DB, seedIDs *SeedIDs) error {
 	tags := []schema.Tag{
 		{Name: "charge"},
 		{Name: "clock"},
@@ -142,9 +195,7 @@ func (d *Domain) seedTags(seedIDs *SeedIDs) error {
 }
 
 // seedContent seeds content data using the user IDs from seedIDs to assign ownership.
-func (d *Domain) seedContent(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedContent(db *gorm.DB, seedIDs *SeedIDs) error {
 	contents := []schema.Content{
 		{
 			OwnerID: seedIDs.Users["michael"],
@@ -265,9 +316,7 @@ func (d *Domain) seedContent(seedIDs *SeedIDs) error {
 
 // seedContentTags attaches tags to content. Uncomment the call in SeedAll()
 // to use it.
-func (d *Domain) seedContentTags(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedContentTags(db *gorm.DB, seedIDs *SeedIDs) error {
 	contentTags := []schema.ContentTag{
 		{
 			ContentID: seedIDs.Contents["Michael's Content 1"],
@@ -392,9 +441,7 @@ func (d *Domain) seedContentTags(seedIDs *SeedIDs) error {
 }
 
 // seedDiscussions seeds discussion data, linking owners and content by IDs.
-func (d *Domain) seedDiscussions(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedDiscussions(db *gorm.DB, seedIDs *SeedIDs) error {
 	discussions := []schema.Discussion{
 		{
 			OwnerID:   seedIDs.Users["michael"],
@@ -455,9 +502,7 @@ func (d *Domain) seedDiscussions(seedIDs *SeedIDs) error {
 }
 
 // seedNotes seeds note data, linking owners and content by IDs.
-func (d *Domain) seedNotes(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedNotes(db *gorm.DB, seedIDs *SeedIDs) error {
 	notes := []schema.Note{
 		{
 			OwnerID:   seedIDs.Users["michael"],
@@ -518,9 +563,7 @@ func (d *Domain) seedNotes(seedIDs *SeedIDs) error {
 }
 
 // seedDiscussionReplies seeds replies from different people to existing discussions.
-func (d *Domain) seedDiscussionReplies(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedDiscussionReplies(db *gorm.DB, seedIDs *SeedIDs) error {
 	// Example with just one reply. Add more as needed.
 	replies := []schema.DiscusionReply{
 		{
@@ -544,9 +587,7 @@ func (d *Domain) seedDiscussionReplies(seedIDs *SeedIDs) error {
 }
 
 // seedNoteReplies seeds replies from different people to existing notes.
-func (d *Domain) seedNoteReplies(seedIDs *SeedIDs) error {
-	db := d.params.DB.GetDB()
-
+func (d *Domain) seedNoteReplies(db *gorm.DB, seedIDs *SeedIDs) error {
 	// Example with just one reply. Add more as needed.
 	replies := []schema.NoteReply{
 		{
@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Domain struct {
+	scope  string
+	logger *zap.Logger
+	config *Config
+	params Params
+
+	// tenants by slug, including unknown slugs so lookups of bad
+	// identifiers do not hit the database on every request
+	cache   map[string]cachedTenant
+	cacheMu sync.Mutex
+}
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	DB        *pgconn.Module
+	Server    *server.Module
+}
+
+type Config struct {
+	CacheTTL time.Duration `mapstructure:"cache_ttl" validate:"gte=0"`
+}
+
+const (
+	DefaultCacheTTL = time.Minute
+)
+
+// ! Domain ---------------------------------------------------------------
+
+func InjectDomain(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Domain {
+			d := &Domain{scope: scope}
+			d.params = p
+			d.logger = d.setupLogger(scope, p)
+			d.config = d.setupConfig(scope)
+			d.cache = make(map[string]cachedTenant)
+			d.setupRoutes()
+
+			return d
+		}),
+		fx.Invoke(func(d *Domain, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: d.onStart,
+					OnStop:  d.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// ! Internal ---------------------------------------------------------------
+func (d *Domain) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (d *Domain) setupConfig(scope string) *Config {
+	c := &Config{
+		CacheTTL: DefaultCacheTTL,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (d *Domain) setupRoutes() {
+	if !d.params.Server.IsMultiTenant() {
+		return
+	}
+
+	// resolved before routing, the path location changes the routed path
+	d.params.Server.GetServer().Pre(d.resolveTenant)
+}
+
+func (d *Domain) onStart(ctx context.Context) error {
+	d.logger.Info("Starting tenant domain.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		d.logConfigurations()
+	}
+
+	if !d.params.Server.IsMultiTenant() {
+		// isolated tables without resolved tenants would fail every request
+		if d.params.DB.Tenancy() != pgconn.TenancyNone {
+			return fmt.Errorf("database.tenancy %s requires server.is_multi_tenant", d.params.DB.Tenancy())
+		}
+		d.logger.Info("Multi tenancy disabled.")
+	}
+
+	return nil
+}
+
+func (d *Domain) onStop(ctx context.Context) error {
+	d.logger.Info("Stopping tenant domain.")
+	return nil
+}
+
+func (d *Domain) logConfigurations() {
+	d.logger.Debug("----- Tenant Configuration -----")
+	d.logger.Debug("CacheTTL", zap.Duration("cache_ttl", d.config.CacheTTL))
+	d.logger.Debug("-------------------------------")
+}
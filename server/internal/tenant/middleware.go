@@ -0,0 +1,93 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"funcedup/internal/schema"
+	"funcedup/pkg/logger"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type cachedTenant struct {
+	tenant    *schema.Tenant // nil for unknown or disabled tenants
+	expiresAt time.Time
+}
+
+// resolveTenant reads the tenant identifier of API requests, rejects
+// requests for unknown or disabled tenants and stores the tenant on the echo
+// context and, for pgconn, on the request context.
+func (d *Domain) resolveTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		identifier := d.params.Server.TenantIdentifier(c)
+
+		req := c.Request()
+		if req.Method == http.MethodOptions || !strings.HasPrefix(req.URL.Path, server.APIPrefix) {
+			return next(c)
+		}
+		if identifier == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Missing tenant")
+		}
+
+		tenant, err := d.lookup(c, identifier)
+		if err != nil {
+			return fmt.Errorf("failed to resolve tenant %s: %w", identifier, err)
+		}
+		if tenant == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Unknown tenant")
+		}
+
+		ctx := pgconn.WithTenant(req.Context(), pgconn.Tenant{
+			ID:     tenant.ID,
+			Slug:   tenant.Slug,
+			Schema: tenant.Schema,
+		})
+		ctx = logger.WithFields(ctx, zap.String("tenant", tenant.Slug))
+
+		c.Set(server.ContextKeyTenant, tenant)
+		c.SetRequest(req.WithContext(ctx))
+		return next(c)
+	}
+}
+
+// lookup returns the enabled tenant with the given slug, or nil.
+func (d *Domain) lookup(c echo.Context, slug string) (*schema.Tenant, error) {
+	slug = strings.ToLower(slug)
+
+	d.cacheMu.Lock()
+	cached, ok := d.cache[slug]
+	d.cacheMu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.tenant, nil
+	}
+
+	tenant, err := FindTenant(d.params.DB.GetDB().WithContext(c.Request().Context()), slug)
+	if errors.Is(err, ErrTenantNotFound) {
+		tenant, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tenant != nil && tenant.IsDisabled() {
+		tenant = nil
+	}
+
+	d.cacheMu.Lock()
+	d.cache[slug] = cachedTenant{tenant: tenant, expiresAt: time.Now().Add(d.config.CacheTTL)}
+	d.cacheMu.Unlock()
+
+	return tenant, nil
+}
+
+// GetTenant returns the tenant stored on the echo context.
+func GetTenant(c echo.Context) (*schema.Tenant, bool) {
+	tenant, ok := c.Get(server.ContextKeyTenant).(*schema.Tenant)
+	return tenant, ok
+}
@@ -0,0 +1,103 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"funcedup/internal/schema"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTenantNotFound = errors.New("tenant not found")
+	ErrTenantExists   = errors.New("tenant already exists")
+	ErrInvalidSlug    = errors.New("tenant slug must be lowercase letters, digits and dashes")
+	ErrInvalidSchema  = errors.New("tenant schema must be lowercase letters, digits and underscores, starting with a letter")
+)
+
+var (
+	// slugs double as subdomains and path segments
+	slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	// postgres identifiers that never need quoting, at most 63 bytes
+	schemaPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+)
+
+// CreateTenant registers a new tenant.
+// Usable outside of the fx app, e.g. from the command line.
+func CreateTenant(db *gorm.DB, slug string, name string, schemaName string) (*schema.Tenant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !slugPattern.MatchString(slug) {
+		return nil, ErrInvalidSlug
+	}
+	if err := ValidateSchema(schemaName); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = slug
+	}
+
+	tenant := schema.Tenant{Slug: slug, Name: name, Schema: schemaName}
+	err := db.Create(&tenant).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, fmt.Errorf("%w: %s", ErrTenantExists, slug)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tenant, nil
+}
+
+// ValidateSchema accepts an empty schema, for row tenancy, or a plain postgres
+// identifier. pg_ prefixed names are reserved by postgres.
+func ValidateSchema(schemaName string) error {
+	if schemaName == "" {
+		return nil
+	}
+	if !schemaPattern.MatchString(schemaName) || strings.HasPrefix(schemaName, "pg_") || schemaName == "public" {
+		return fmt.Errorf("%w: %s", ErrInvalidSchema, schemaName)
+	}
+	return nil
+}
+
+// FindTenant looks up a tenant by slug.
+// Returns ErrTenantNotFound if there is none.
+func FindTenant(db *gorm.DB, slug string) (*schema.Tenant, error) {
+	var tenant schema.Tenant
+	err := db.Where("slug = ?", strings.ToLower(slug)).First(&tenant).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// ListTenants returns all tenants ordered by slug.
+func ListTenants(db *gorm.DB) ([]schema.Tenant, error) {
+	var tenants []schema.Tenant
+	err := db.Order("slug").Find(&tenants).Error
+	return tenants, err
+}
+
+// DisableTenant rejects further requests for the tenant. Running servers
+// notice after tenant.cache_ttl.
+func DisableTenant(db *gorm.DB, slug string) (*schema.Tenant, error) {
+	tenant, err := FindTenant(db, slug)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.IsDisabled() {
+		return tenant, nil
+	}
+
+	if err := db.Model(tenant).Update("disabled_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
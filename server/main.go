@@ -1,18 +1,20 @@
 package main
 
 import (
-	"funcedup/internal/schema"
-	"funcedup/internal/seeder"
+	"errors"
+	"fmt"
+	"os"
+
 	"funcedup/pkg/config"
 	"funcedup/pkg/logger"
 	"funcedup/pkg/pgconn"
-	"funcedup/pkg/server"
 
 	"go.uber.org/fx"
 )
 
 func init() {
-	//! CONFIG PRECEDENCE: OVERRIDE > ENV > CONFIG FILE > FALLBACK
+	//! CONFIG PRECEDENCE: OVERRIDE > ENV > CONFIG OVERRIDE FILE > CONFIG FILE > DEFAULT
+	// `config print` and GET /api/v1/admin/config show where each value comes from
 
 	// *OPTIONAL* OVERRIDE GLOBAL LOG LEVEL, INTENDED FOR DEVELOPMENT
 	// config.OverrideGlobalLogLevel("debug")
@@ -20,30 +22,103 @@ func init() {
 	config.SetUpConfig("SERVER", "yaml", "./")
 }
 
+const usage = `Usage: main <command> [arguments]
+
+Commands:
+  serve                                   run the http server (default)
+  migrate up [--dry-run]                  apply pending migrations
+  migrate down [--steps n] [--dry-run]    roll back the latest migrations
+  migrate status                          list applied and pending migrations
+  seed [--profile p | --fixtures path] [--tenant t] [--reset]
+                                          seed test data, --reset truncates first
+  seed --generate [--seed n] [--users n] [--content-per-user n] ...
+                                          generate synthetic data, see seed -h
+  config print [--flat]                   print the merged configuration and its sources
+  user create --username u --email e [--password p | --password-stdin] [--role r] [--tenant t]
+  user promote [--role r] [--tenant t] <username|email>
+  user disable [--tenant t] <username|email>
+  tenant create [--name n] [--schema s] <slug>
+  tenant list
+  tenant disable <slug>
+  rls print                               print row level security policies
+  rls apply [--dry-run]                   create or replace the policies
+  rls verify                              list tables lacking policies
+`
+
 func main() {
-	app := fx.New(
-		//* Modules ---------------------------------------------------------------
+	args := os.Args[1:]
+
+	// no command keeps the previous behaviour of `go run .` and air
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "seed":
+		err = runSeed(args)
+	case "config":
+		err = runConfig(args)
+	case "user":
+		err = runUser(args)
+	case "tenant":
+		err = runTenant(args)
+	case "rls":
+		err = runRLS(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// Modules every command touching the database needs.
+func databaseModules() fx.Option {
+	return fx.Options(
 		logger.InjectModule("logger"),
 		pgconn.InjectModule("database"),
-		server.InjectModule("server"),
-		//* Domains ---------------------------------------------------------------
-		seeder.InjectDomain("seeder"),
-		//* Migration -------------------------------------------------------------
-		fx.Invoke(func(m *pgconn.Module) {
-			m.ApplySchema(
-				true,
-				schema.User{},
-				schema.Content{},
-				schema.Discussion{},
-				schema.DiscusionReply{},
-				schema.Note{},
-				schema.NoteReply{},
-				schema.Tag{},
-				schema.ContentTag{},
-			)
-		}),
-		//* fx logs ---------------------------------------------------------------
+	)
+}
+
+// runTask builds an fx app from options and runs task through fx.Invoke
+// without starting the lifecycle, so one-off commands only construct the
+// modules they ask for and never start the server.
+func runTask(options fx.Option, task interface{}) error {
+	var db *pgconn.Module
+
+	app := fx.New(
+		options,
+		// module invokes run first, the modules of options are loaded by now
+		fx.Invoke(config.Check),
+		fx.Populate(&db),
+		fx.Invoke(task),
 		fx.NopLogger,
 	)
-	app.Run()
+
+	if db != nil {
+		db.Close()
+	}
+
+	return configReport(app.Err())
+}
+
+// configReport returns the config.Report within err, without the chain of
+// constructors fx wraps it in. Other errors are returned as they are.
+func configReport(err error) error {
+	var report config.Report
+	if errors.As(err, &report) {
+		return report
+	}
+	return err
 }
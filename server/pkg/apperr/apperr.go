@@ -0,0 +1,249 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Machine readable error codes sent to clients.
+const (
+	CodeBadRequest    = "bad_request"
+	CodeValidation    = "validation_failed"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeNotFound      = "not_found"
+	CodeConflict      = "conflict"
+	CodeUnprocessable = "unprocessable"
+	CodeInternal      = "internal"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// Error is the error model of the API, it is rendered as the response body.
+// e.g. {"code":"validation_failed","message":"Invalid request","fields":[...]}
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+
+	// Cause is logged for server errors but never sent to clients.
+	Cause error `json:"-"`
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns a new Error, an empty message defaults to the status text.
+func New(status int, code string, message string) *Error {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func Unprocessable(message string) *Error {
+	return New(http.StatusUnprocessableEntity, CodeUnprocessable, message)
+}
+
+// Returns a 500 error hiding cause from the client.
+func Internal(cause error) *Error {
+	e := New(http.StatusInternalServerError, CodeInternal, "")
+	e.Cause = cause
+	return e
+}
+
+// Returns a copy of e with the cause attached.
+func (e *Error) WithCause(cause error) *Error {
+	clone := *e
+	clone.Cause = cause
+	return &clone
+}
+
+// From converts any error returned by a handler into an Error.
+//   - *Error is returned as is
+//   - validator.ValidationErrors -> 400 with one FieldError per field
+//   - *echo.HTTPError -> its status and message
+//   - gorm.ErrRecordNotFound -> 404
+//   - unique violations -> 409, foreign key violations -> 422
+//   - anything else -> 500
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return Validation(validationErrs)
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		e := New(httpErr.Code, CodeFromStatus(httpErr.Code), fmt.Sprint(httpErr.Message))
+		e.Cause = httpErr.Internal
+		return e
+	}
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound("").WithCause(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey),
+		errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+		return Conflict("Resource already exists").WithCause(err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated),
+		errors.As(err, &pgErr) && pgErr.Code == pgForeignKeyViolation:
+		return Unprocessable("Referenced resource does not exist").WithCause(err)
+	}
+
+	return Internal(err)
+}
+
+// Validation builds a 400 error listing every rejected field. Field names are
+// the ones reported by the validator, see server.CustomValidator.
+func Validation(errs validator.ValidationErrors) *Error {
+	e := New(http.StatusBadRequest, CodeValidation, "Invalid request")
+	e.Cause = errs
+	for _, fe := range errs {
+		e.Fields = append(e.Fields, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return e
+}
+
+// CodeFromStatus derives a code from an HTTP status.
+// e.g. 429 -> "too_many_requests"
+func CodeFromStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusInternalServerError:
+		return CodeInternal
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return CodeInternal
+	}
+	return strings.ToLower(strings.ReplaceAll(text, " ", "_"))
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// fieldPath strips the struct name from the namespace of a field.
+// e.g. "CreateContentRequest.tags[0]" -> "tags[0]"
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	param := fe.Param()
+
+	switch fe.Tag() {
+	case "required", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "alphanum":
+		return "must only contain letters and digits"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "min", "gte":
+		return "must be at least " + param + lengthUnit(fe)
+	case "max", "lte":
+		return "must be at most " + param + lengthUnit(fe)
+	case "len":
+		return "must be exactly " + param + lengthUnit(fe)
+	case "gt":
+		return "must be greater than " + param + lengthUnit(fe)
+	case "lt":
+		return "must be less than " + param + lengthUnit(fe)
+	}
+
+	if param != "" {
+		return fmt.Sprintf("failed the %s=%s rule", fe.Tag(), param)
+	}
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
+
+// lengthUnit returns the unit min/max rules are measured in for a field.
+func lengthUnit(fe validator.FieldError) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " items"
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Setting is the effective value of a key and the layer it comes from, see
+// Source. Secret values are redacted.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Effective returns every known key sorted by name. Keys of modules are only
+// known once the module loaded its config, see Load.
+func Effective() []Setting {
+	live.RLock()
+	defer live.RUnlock()
+
+	keys := viper.AllKeys()
+	slices.Sort(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, Setting{
+			Key:    key,
+			Value:  redactValue(key, viper.Get(key)),
+			Source: Source(key),
+		})
+	}
+	return settings
+}
+
+// Tree nests settings by the segments of their keys, the leaves are the
+// settings themselves. A key that holds a value and has keys below it, e.g. a
+// map set in code next to a file value below it, keeps its own setting under
+// the empty key of its node.
+// e.g. {"server": {"port": {"key": "server.port", "value": 3001, "source": "default"}}}
+func Tree(settings []Setting) map[string]any {
+	tree := make(map[string]any)
+	for _, setting := range settings {
+		parts := strings.Split(setting.Key, ".")
+		node := tree
+		for _, part := range parts[:len(parts)-1] {
+			switch child := node[part].(type) {
+			case map[string]any:
+				node = child
+			case Setting:
+				// a leaf so far, it moves into the node
+				next := map[string]any{"": child}
+				node[part] = next
+				node = next
+			default:
+				next := make(map[string]any)
+				node[part] = next
+				node = next
+			}
+		}
+
+		last := parts[len(parts)-1]
+		if child, ok := node[last].(map[string]any); ok {
+			child[""] = setting
+			continue
+		}
+		node[last] = setting
+	}
+	return tree
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func redactValue(key string, value any) any {
+	if !IsSecret(key) || value == nil {
+		return value
+	}
+	return Redact(fmt.Sprint(value))
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	port := Setting{Key: "server.port", Value: 3001, Source: SourceDefault}
+	host := Setting{Key: "server.host", Value: "localhost", Source: "config.yaml"}
+	seeder := Setting{Key: "seeder", Value: "off", Source: SourceOverride}
+	enabled := Setting{Key: "seeder.enabled", Value: true, Source: "config.yaml"}
+
+	tests := []struct {
+		name     string
+		settings []Setting
+		want     map[string]any
+	}{
+		{
+			name:     "nested",
+			settings: []Setting{port, host},
+			want:     map[string]any{"server": map[string]any{"port": port, "host": host}},
+		},
+		{
+			name:     "leaf before parent",
+			settings: []Setting{seeder, enabled},
+			want:     map[string]any{"seeder": map[string]any{"": seeder, "enabled": enabled}},
+		},
+		{
+			name:     "parent before leaf",
+			settings: []Setting{enabled, seeder},
+			want:     map[string]any{"seeder": map[string]any{"": seeder, "enabled": enabled}},
+		},
+		{
+			name:     "top level",
+			settings: []Setting{{Key: "build_env", Value: "dev", Source: SourceEnv}},
+			want:     map[string]any{"build_env": Setting{Key: "build_env", Value: "dev", Source: SourceEnv}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _ := json.Marshal(Tree(test.settings))
+			want, _ := json.Marshal(test.want)
+			if string(got) != string(want) {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
+
+// Problem is a config value Load rejected.
+type Problem struct {
+	Key     string
+	Value   any
+	Source  string
+	Message string
+}
+
+// Report lists every problem found by Load, see Check.
+type Report []Problem
+
+func (r Report) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d problem(s):", len(r))
+	for _, p := range r {
+		fmt.Fprintf(&b, "\n  %s = %s (%s): %s", p.Key, formatValue(p.Key, p.Value), p.Source, p.Message)
+	}
+	return b.String()
+}
+
+var (
+	reportMu sync.Mutex
+	report   Report
+
+	// file values by source, to tell which file a value comes from, and the
+	// keys set in code with their values, which a reload layers over the
+	// files again
+	sourcesMu sync.RWMutex
+	sources   struct {
+		base          *viper.Viper
+		defaults      *viper.Viper
+		profile       *viper.Viper
+		override      *viper.Viper
+		set           map[string]string
+		setValues     map[string]any
+		defaultValues map[string]any
+	}
+
+	validate = newValidator()
+)
+
+// Sources of a config value, from lowest to highest precedence, files are
+// named by their file name.
+const (
+	SourceDefault  = "default"
+	SourceProfile  = "profile"
+	SourceEnv      = "env"
+	SourceFallback = "fallback"
+	SourceOverride = "override"
+)
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Load fills target, a pointer to a struct holding the defaults of scope, with
+// the effective value of every field tagged with `mapstructure`, then checks
+// the `validate` tags. Nested structs are loaded from the keys below their tag.
+// String values are resolved, see Resolve. Fields tagged `config:"secret"` are
+// redacted and must not keep a non-empty default in production.
+// Problems are not returned but collected for Check, so one report lists the
+// problems of every scope.
+//
+// e.g.
+//
+//	type Config struct {
+//		Port     int    `mapstructure:"port" validate:"min=1,max=65535"`
+//		Password string `mapstructure:"password" config:"secret"`
+//	}
+//	c := &Config{Port: DefaultPort}
+//	config.Load(scope, c)
+func Load(scope string, target any) {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config.Load: target of %s must be a pointer to a struct, got %T", scope, target))
+	}
+
+	var problems Report
+	failed := make(map[string]bool)
+	loadStruct(scope, value.Elem(), &problems, failed)
+
+	if err := validate.Struct(target); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			panic(fmt.Sprintf("config.Load: %s: %v", scope, err))
+		}
+		for _, fe := range errs {
+			key := scope + "." + fieldPath(fe)
+			// a value that did not decode is only reported once
+			if failed[key] {
+				continue
+			}
+			problems = append(problems, newProblem(key, fieldMessage(fe)))
+		}
+	}
+
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	for _, p := range problems {
+		// scopes shared by modules, e.g. global, are loaded more than once
+		if !slices.ContainsFunc(report, func(existing Problem) bool {
+			return existing.Key == p.Key && existing.Message == p.Message
+		}) {
+			report = append(report, p)
+		}
+	}
+}
+
+// Check returns the Report of every problem Load found so far, nil without
+// problems. Commands invoke it once all modules are constructed, so startup
+// stops before anything runs with a bad config.
+// e.g. fx.Invoke(config.Check)
+func Check() error {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	if len(report) == 0 {
+		return nil
+	}
+	return slices.Clone(report)
+}
+
+// Source names where the effective value of key comes from, "override" or
+// "fallback" for values set in code, "env" with the variable name, the config
+// file, "profile" with the name for safety defaults of a profile or "default".
+// e.g. Source("server.port") -> "env SERVER_SERVER_PORT"
+func Source(key string) string {
+	key = strings.ToLower(key)
+
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+
+	if source, ok := sources.set[key]; ok {
+		return source
+	}
+	if name := envName(key); name != "" {
+		if _, ok := os.LookupEnv(name); ok {
+			return SourceEnv + " " + name
+		}
+	}
+	if sources.override != nil && sources.override.InConfig(key) {
+		return overrideConfigName + "." + setup.configFileType
+	}
+	if sources.profile != nil && sources.profile.InConfig(key) {
+		return profileConfigName() + "." + setup.configFileType
+	}
+	if sources.defaults != nil && sources.defaults.InConfig(key) {
+		return SourceProfile + " " + setup.profile
+	}
+	if sources.base != nil && sources.base.InConfig(key) {
+		return baseConfigName + "." + setup.configFileType
+	}
+	return SourceDefault
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func loadStruct(prefix string, value reflect.Value, problems *Report, failed map[string]bool) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if tag == "" || tag == "-" || !field.IsExported() {
+			continue
+		}
+		key := prefix + "." + tag
+		fieldValue := value.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			loadStruct(key, fieldValue, problems, failed)
+			continue
+		}
+
+		// the prefilled value is the default, registered so that viper knows
+		// the key, e.g. for env lookups
+		SetDefault(key, fieldValue.Interface())
+
+		secret := slices.Contains(strings.Split(field.Tag.Get("config"), ","), "secret")
+		if secret {
+			markSecret(key)
+		}
+
+		decoded := reflect.New(field.Type)
+		live.RLock()
+		err := viper.UnmarshalKey(key, decoded.Interface())
+		live.RUnlock()
+		if err != nil {
+			*problems = append(*problems, newProblem(key, "expected "+typeName(field.Type)))
+			failed[key] = true
+			continue
+		}
+
+		if decoded.Elem().Kind() == reflect.String {
+			resolved, err := Resolve(decoded.Elem().String())
+			if err != nil {
+				*problems = append(*problems, newProblem(key, err.Error()))
+				failed[key] = true
+				continue
+			}
+			decoded.Elem().SetString(resolved)
+		}
+
+		if secret && IsProduction() && !fieldValue.IsZero() && decoded.Elem().Equal(fieldValue) {
+			*problems = append(*problems, newProblem(key, "still holds the default, set a secret in production"))
+			failed[key] = true
+			continue
+		}
+
+		fieldValue.Set(decoded.Elem())
+	}
+}
+
+func newProblem(key string, message string) Problem {
+	live.RLock()
+	value := viper.Get(key)
+	live.RUnlock()
+
+	return Problem{
+		Key:     key,
+		Value:   value,
+		Source:  Source(key),
+		Message: message,
+	}
+}
+
+// envName is the variable viper reads key from, see SetUpConfig.
+func envName(key string) string {
+	name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if setup.envPrefix != "" {
+		name = strings.ToUpper(setup.envPrefix) + "_" + name
+	}
+	return name
+}
+
+// setSource records that key was set in code to value, viper does not tell.
+func setSource(key string, source string, value any) {
+	key = strings.ToLower(key)
+
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	if sources.set == nil {
+		sources.set = make(map[string]string)
+		sources.setValues = make(map[string]any)
+	}
+	sources.set[key] = source
+	sources.setValues[key] = value
+}
+
+// setDefaultValue records the default of key for reloads.
+func setDefaultValue(key string, value any) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	if sources.defaultValues == nil {
+		sources.defaultValues = make(map[string]any)
+	}
+	sources.defaultValues[strings.ToLower(key)] = value
+}
+
+// setSources keeps the file values apart, viper only holds them merged.
+func setSources(files configFiles) {
+	parse := func(content []byte) *viper.Viper {
+		if content == nil {
+			return nil
+		}
+		v := viper.New()
+		v.SetConfigType(setup.configFileType)
+		if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+			return nil
+		}
+		return v
+	}
+
+	var defaults *viper.Viper
+	if settings := profileSettings(); settings != nil {
+		defaults = viper.New()
+		defaults.MergeConfigMap(settings)
+	}
+
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources.base = parse(files.base)
+	sources.defaults = defaults
+	sources.profile = parse(files.profile)
+	sources.override = parse(files.override)
+}
+
+func formatValue(key string, value any) string {
+	if IsSecret(key) {
+		return Redacted
+	}
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func typeName(t reflect.Type) string {
+	switch t.String() {
+	case "time.Duration":
+		return "a duration, e.g. 30s"
+	case "[]string":
+		return "a list of strings"
+	}
+	return "a value of type " + t.String()
+}
+
+// newValidator reports fields by their mapstructure tag and adds oneofci, a
+// case insensitive oneof.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("oneofci", func(fl validator.FieldLevel) bool {
+		value := fl.Field().String()
+		for _, allowed := range strings.Fields(fl.Param()) {
+			if strings.EqualFold(value, allowed) {
+				return true
+			}
+		}
+		return false
+	})
+	return v
+}
+
+// fieldPath strips the struct name from the namespace of a field.
+// e.g. "Config.generator.users" -> "generator.users"
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	param := fe.Param()
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof", "oneofci":
+		return "must be one of: " + strings.Join(strings.Fields(param), ", ")
+	case "min", "gte":
+		return "must be at least " + param
+	case "max", "lte":
+		return "must be at most " + param
+	case "gt":
+		return "must be greater than " + param
+	case "lt":
+		return "must be less than " + param
+	case "ltefield":
+		return "must not be greater than " + param
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "hostname", "hostname_rfc1123":
+		return "must be a valid host name"
+	case "startswith":
+		return "must start with " + param
+	}
+
+	if param != "" {
+		return fmt.Sprintf("failed the %s=%s rule", fe.Tag(), param)
+	}
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
@@ -2,7 +2,7 @@ package config
 
 import (
 	"log"
-	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -13,14 +13,27 @@ const (
 	defaultEnvPrefix      = ""
 	defaultPrintSettings  = false
 	defaultConfigFilePath = "./"
+
+	baseConfigName     = "config"
+	overrideConfigName = "config.override"
 )
 
+// arguments of SetUpConfig and the profile, a reload reads the same files again
+var setup struct {
+	envPrefix      string
+	configFileType string
+	configFilePath string
+	profile        string
+}
+
 /*
 Sets up the configuration manager.
 prefix: Prefix for environment variables. Defaults to "".
 configFileType: Type of config file. Defaults to "yaml".
 configFilePath: Path to config file. Defaults to "./".
 configFilePath is relative to where the function is called, usually main.go.
+Files are layered config.yaml -> config.<profile>.yaml -> config.override.yaml,
+the profile is read from env, e.g. SERVER_PROFILE, see Profiles.
 */
 func SetUpConfig(prefix string, configFileType string, configFilePath string) {
 
@@ -42,7 +55,15 @@ func SetUpConfig(prefix string, configFileType string, configFilePath string) {
 		viper.AddConfigPath(configFilePath)
 	}
 
-	readConfigWithOptionalOverride("config", "config.override", validatedConfigFileType, configFilePath)
+	setup.envPrefix = prefix
+	setup.configFileType = validatedConfigFileType
+	setup.configFilePath = configFilePath
+	if setup.configFilePath == "" {
+		setup.configFilePath = defaultConfigFilePath
+	}
+
+	setupProfile()
+	readConfigLayers()
 
 	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
 		logConfigurations(prefix, validatedConfigFileType)
@@ -52,40 +73,40 @@ func SetUpConfig(prefix string, configFileType string, configFilePath string) {
 
 //! INTERNAL ---------------------------------------------------------
 
-func readConfigWithOptionalOverride(baseConfig string, overrideConfig string, configFileType string, configFilePath string) {
-	var err error
-
-	//check for presence of base config file
-	_, err = os.Stat(configFilePath + "/" + baseConfig + "." + configFileType)
+func readConfigLayers() {
+	files, err := readConfigFiles()
 	if err != nil {
-		log.Printf("Base config -- %s.%s -- not found.", baseConfig, configFileType)
+		log.Printf("Error reading config files, %s.", err)
 		return
 	}
 
-	// read the base config file
-	viper.SetConfigName(baseConfig)
-	err = viper.ReadInConfig()
-	if err != nil {
-		log.Printf("Error reading base config file, %s.", err)
-		return
+	if files.base == nil {
+		log.Printf("Base config -- %s.%s -- not found.", baseConfigName, setup.configFileType)
+	}
+	if setup.profile != "" && files.profile == nil {
+		log.Printf("Profile config -- %s.%s -- not found, applying the defaults of profile %s.", profileConfigName(), setup.configFileType, setup.profile)
 	}
-	log.Printf("Base config applied from -- %s\n --", viper.ConfigFileUsed())
 
-	// check for presence of override config file
-	_, err = os.Stat(configFilePath + "/" + overrideConfig + "." + configFileType)
-	if err != nil {
-		log.Printf("Skipping override. Override config -- %s.%s -- not found.", overrideConfig, configFileType)
+	setSources(files)
+	// files and profile defaults are merged in order, the first invalid file
+	// leaves the layers above it unapplied
+	if err := applyConfigFiles(viper.GetViper(), files); err != nil {
+		log.Printf("Error reading config files, %s.", err)
 		return
 	}
 
-	// merge the override config file with the base config file
-	viper.SetConfigName(overrideConfig)
-	err = viper.MergeInConfig()
-	if err != nil {
-		log.Printf("Error reading override config file, %s.", err)
-		return
+	for _, layer := range []struct {
+		name    string
+		content []byte
+	}{
+		{baseConfigName, files.base},
+		{profileConfigName(), files.profile},
+		{overrideConfigName, files.override},
+	} {
+		if layer.content != nil {
+			log.Printf("Config applied from -- %s --", filepath.Join(setup.configFilePath, layer.name+"."+setup.configFileType))
+		}
 	}
-	log.Printf("Override config applied from -- %s\n --", viper.ConfigFileUsed())
 }
 
 func validateConfigFileType(configFileType string) string {
@@ -116,7 +137,8 @@ func logConfigurations(prefix string, configFileType string) {
 	log.Printf("|| Prefix   %s", prefix)
 	log.Printf("|| replacer %s", ". -> _")
 	log.Printf("|| autoEnv  %s", "true")
-	log.Printf("|| name     %s", "config AND config.override[OPTIONAL]")
+	log.Printf("|| name     %s", "config AND config.<profile>, config.override[OPTIONAL]")
+	log.Printf("|| profile  %s", setup.profile)
 	log.Printf("|| paths    %s", defaultConfigFilePath)
 	log.Printf("|| fileType %s", configFileType)
 	log.Println("------------------------")
@@ -124,12 +146,33 @@ func logConfigurations(prefix string, configFileType string) {
 
 //! External ---------------------------------------------------------
 
+// Sets the value of key above env and config files, e.g. from a command line
+// flag. Source reports it as "override".
+func Set(key string, value any) {
+	live.Lock()
+	viper.Set(key, value)
+	live.Unlock()
+	setSource(key, SourceOverride, value)
+}
+
+// Sets the value of key used when neither env nor a config file provide one.
+func SetDefault(key string, value any) {
+	live.Lock()
+	viper.SetDefault(key, value)
+	live.Unlock()
+	setDefaultValue(key, value)
+}
+
 // Sets values for the config keys that are not provided.
 // ! IMPORTANT: Set is absolute. Run this function last to avoid overriding.
 func SetFallbackConfigs(configs map[string]interface{}) {
+	live.Lock()
+	defer live.Unlock()
+
 	for k, v := range configs {
 		if !viper.IsSet(k) {
 			viper.Set(k, v)
+			setSource(k, SourceFallback, v)
 		}
 	}
 }
@@ -137,9 +180,9 @@ func SetFallbackConfigs(configs map[string]interface{}) {
 // Sets value for global.log_level, defaults to "INFO"
 func OverrideGlobalLogLevel(logLevel string) {
 	if logLevel == "" {
-		viper.Set("global.log_level", "dev")
+		Set("global.log_level", "dev")
 		return
 	}
 
-	viper.Set("global.log_level", logLevel)
+	Set("global.log_level", logLevel)
 }
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// Profiles select config.<profile>.yaml, layered between config.yaml and
+// config.override.yaml, and the safety defaults below.
+// e.g. SERVER_PROFILE=staging
+const (
+	ProfileDev     = "dev"
+	ProfileTest    = "test"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// profileKey is read from env only, e.g. SERVER_PROFILE, the files depend on it.
+const profileKey = "profile"
+
+// profileDefaults are applied above config.yaml, which is written for local
+// development, and below config.<profile>.yaml, so every profile file,
+// config.override.yaml and env can still change them deliberately.
+var profileDefaults = map[string]map[string]any{
+	ProfileDev: {
+		"seeder.enabled": true,
+	},
+	ProfileTest: {
+		"seeder.enabled": true,
+	},
+	ProfileStaging: {
+		"seeder.enabled": false,
+	},
+	ProfileProd: {
+		"seeder.enabled":     false,
+		"server.csrf_secure": true,
+		"auth.cookie_secure": true,
+		"logger.encoding":    "json",
+		"logger.color":       false,
+	},
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Profile returns the active profile, empty without one.
+func Profile() string {
+	return setup.profile
+}
+
+// Profiles lists the known profiles.
+func Profiles() []string {
+	return []string{ProfileDev, ProfileTest, ProfileStaging, ProfileProd}
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// setupProfile reads the profile from env. An unknown profile is reported by
+// Check and no profile is applied.
+func setupProfile() {
+	name := envName(profileKey)
+	value, _ := os.LookupEnv(name)
+	profile := strings.ToLower(strings.TrimSpace(value))
+
+	// known to viper, so the profile is part of the effective config
+	SetDefault(profileKey, "")
+
+	if profile != "" && !slices.Contains(Profiles(), profile) {
+		reportMu.Lock()
+		report = append(report, newProblem(profileKey, "must be one of: "+strings.Join(Profiles(), ", ")))
+		reportMu.Unlock()
+		profile = ""
+	}
+	setup.profile = profile
+}
+
+// profileConfigName is the name of the config file of the active profile,
+// empty without one.
+func profileConfigName() string {
+	if setup.profile == "" {
+		return ""
+	}
+	return baseConfigName + "." + setup.profile
+}
+
+// profileSettings returns the safety defaults of the active profile nested
+// like a config file, nil without any.
+func profileSettings() map[string]any {
+	defaults := profileDefaults[setup.profile]
+	if len(defaults) == 0 {
+		return nil
+	}
+
+	settings := make(map[string]any)
+	for key, value := range defaults {
+		parts := strings.Split(key, ".")
+		node := settings
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+	return settings
+}
@@ -0,0 +1,498 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Module reloads config.yaml, config.<profile>.yaml and config.override.yaml
+// while the server runs and notifies subscribers of changed keys. Without config.watch nothing is
+// watched, Reload still works.
+//
+// Modules snapshot their config when they are constructed, a changed value
+// only takes effect where a subscription applies it.
+type Module struct {
+	config *Config
+	logger *zap.Logger
+	scope  string
+
+	// serializes reloads and guards subscriptions
+	mu            sync.Mutex
+	subscriptions []*Subscription
+
+	// effective config as of the start or the last reload, see Settings
+	snapshot atomic.Pointer[[]Setting]
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// live guards the global viper, a reload writes it while requests read it.
+// Writers in this package take it, see Set, readers outside of it go through
+// Settings or the reloaded config passed to subscriptions.
+var live sync.RWMutex
+
+type Params struct {
+	fx.In
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+}
+
+type Config struct {
+	Watch    bool          `mapstructure:"watch"`
+	Debounce time.Duration `mapstructure:"debounce" validate:"gte=0"`
+}
+
+const (
+	DefaultWatch = false
+	// editors write files in several steps, they are read once writes settle
+	DefaultDebounce = 250 * time.Millisecond
+)
+
+// Subscription reacts to reloads changing one of Keys or a key below one of
+// them, e.g. "server" matches "server.allow_origins".
+type Subscription struct {
+	Keys []string
+
+	// Validate is called with the reloaded config before it goes live, read
+	// the new values from next. An error rejects the reload, the live config
+	// stays as it is.
+	Validate func(next *viper.Viper) error
+	// Apply is called once every subscription accepted the reload and the
+	// config is live, with the changes matching Keys. It runs on the watcher
+	// goroutine.
+	Apply func(next *viper.Viper, changes []Change)
+}
+
+// Change of the effective value of a key, after env and defaults.
+type Change struct {
+	Key string
+	Old any
+	New any
+}
+
+//! MODULE ---------------------------------------------------------------
+
+// Provides the module to the fx framework
+func InjectModule(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) (*Module, error) {
+
+			m := &Module{scope: scope}
+			m.config = m.setupConfig(scope)
+			m.logger = m.setupLogger(scope, p)
+
+			// a reload rereads them, unreadable files fail now rather than then
+			if _, err := readConfigFiles(); err != nil {
+				return nil, err
+			}
+
+			return m, nil
+		}),
+		fx.Invoke(func(m *Module, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: m.onStart,
+					OnStop:  m.onStop,
+				},
+			)
+		}),
+	)
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func (m *Module) setupConfig(scope string) *Config {
+	c := &Config{
+		Watch:    DefaultWatch,
+		Debounce: DefaultDebounce,
+	}
+	Load(scope, c)
+
+	return c
+}
+
+func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (m *Module) onStart(ctx context.Context) error {
+	m.logger.Info("Starting config module.")
+
+	if strings.EqualFold(viper.GetString("global.log_level"), "debug") {
+		m.logConfigurations()
+	}
+
+	// every module loaded its config by now
+	m.takeSnapshot()
+
+	if !m.config.Watch {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config: %w", err)
+	}
+	// the directory, editors and config maps replace files instead of
+	// writing them in place
+	if err := watcher.Add(setup.configFilePath); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config: %w", err)
+	}
+	m.watcher = watcher
+	m.done = make(chan struct{})
+	go m.watch()
+
+	m.logger.Info("Watching config files", zap.String("path", setup.configFilePath))
+	return nil
+}
+
+func (m *Module) onStop(ctx context.Context) error {
+	m.logger.Info("Stopping config module.")
+
+	if m.watcher == nil {
+		return nil
+	}
+	close(m.done)
+	return m.watcher.Close()
+}
+
+func (m *Module) watch() {
+	names := []string{
+		baseConfigName + "." + setup.configFileType,
+		overrideConfigName + "." + setup.configFileType,
+	}
+	if name := profileConfigName(); name != "" {
+		names = append(names, name+"."+setup.configFileType)
+	}
+
+	var debounce *time.Timer
+	for {
+		select {
+		case <-m.done:
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+		case err, ok := <-m.watcher.Errors:
+			if !ok {
+				return
+			}
+			m.logger.Error("Error watching config files", zap.Error(err))
+		case event, ok := <-m.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) || !slices.Contains(names, filepath.Base(event.Name)) {
+				continue
+			}
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(m.config.Debounce, func() {
+				if err := m.Reload(); err != nil {
+					m.logger.Error("Config reload rejected, keeping the previous config", zap.Error(err))
+				}
+			})
+		}
+	}
+}
+
+func (m *Module) logConfigurations() {
+	m.logger.Debug("----- Config Configuration -----")
+	m.logger.Debug("Profile", zap.String("profile", Profile()))
+	m.logger.Debug("Watch", zap.Bool("watch", m.config.Watch))
+	m.logger.Debug("Debounce", zap.Duration("debounce", m.config.Debounce))
+}
+
+// configFiles holds the content of the config files from lowest to highest
+// precedence, nil for a missing file.
+type configFiles struct {
+	base     []byte
+	profile  []byte
+	override []byte
+}
+
+func readConfigFiles() (configFiles, error) {
+	var files configFiles
+	var err error
+
+	if files.base, err = readConfigFile(baseConfigName); err != nil {
+		return files, err
+	}
+	if name := profileConfigName(); name != "" {
+		if files.profile, err = readConfigFile(name); err != nil {
+			return files, err
+		}
+	}
+	if files.override, err = readConfigFile(overrideConfigName); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+func readConfigFile(name string) ([]byte, error) {
+	content, err := os.ReadFile(filepath.Join(setup.configFilePath, name+"."+setup.configFileType))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return content, err
+}
+
+// applyConfigFiles replaces the file values of v with config.yaml, the safety
+// defaults of the profile, config.<profile>.yaml and config.override.yaml, in
+// that order. Env and overrides still take precedence as before.
+func applyConfigFiles(v *viper.Viper, files configFiles) error {
+	v.SetConfigType(setup.configFileType)
+	if err := v.ReadConfig(bytes.NewReader(files.base)); err != nil {
+		return fmt.Errorf("invalid %s.%s: %w", baseConfigName, setup.configFileType, err)
+	}
+	if settings := profileSettings(); settings != nil {
+		if err := v.MergeConfigMap(settings); err != nil {
+			return fmt.Errorf("invalid defaults of profile %s: %w", setup.profile, err)
+		}
+	}
+	if files.profile != nil {
+		if err := v.MergeConfig(bytes.NewReader(files.profile)); err != nil {
+			return fmt.Errorf("invalid %s.%s: %w", profileConfigName(), setup.configFileType, err)
+		}
+	}
+	if files.override != nil {
+		if err := v.MergeConfig(bytes.NewReader(files.override)); err != nil {
+			return fmt.Errorf("invalid %s.%s: %w", overrideConfigName, setup.configFileType, err)
+		}
+	}
+	return nil
+}
+
+// candidate builds the config files result in without touching the live
+// config: env like SetUpConfig, the files and the values set in code.
+func candidate(files configFiles) (*viper.Viper, error) {
+	v := viper.New()
+	if setup.envPrefix != "" {
+		v.SetEnvPrefix(setup.envPrefix)
+	}
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	if err := applyConfigFiles(v, files); err != nil {
+		return nil, err
+	}
+
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	for key, value := range sources.defaultValues {
+		v.SetDefault(key, value)
+	}
+	for key, value := range sources.setValues {
+		v.Set(key, value)
+	}
+	return v, nil
+}
+
+// settings returns every key of v with its effective value.
+func settings(v *viper.Viper) map[string]any {
+	values := make(map[string]any)
+	for _, key := range v.AllKeys() {
+		values[key] = v.Get(key)
+	}
+	return values
+}
+
+// apply makes files the live config.
+func (m *Module) apply(files configFiles) error {
+	live.Lock()
+	err := applyConfigFiles(viper.GetViper(), files)
+	live.Unlock()
+	if err != nil {
+		// parsed by candidate already
+		return err
+	}
+	setSources(files)
+	m.takeSnapshot()
+	return nil
+}
+
+func (m *Module) takeSnapshot() {
+	settings := Effective()
+	m.snapshot.Store(&settings)
+}
+
+func diff(before map[string]any, after map[string]any) []Change {
+	var changes []Change
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes = append(changes, Change{Key: key, Old: old, New: value})
+		}
+	}
+	for key, old := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: old})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return changes
+}
+
+// matching returns the changes of keys equal to or below one of keys.
+func matching(changes []Change, keys []string) []Change {
+	var matched []Change
+	for _, change := range changes {
+		for _, key := range keys {
+			key = strings.ToLower(key)
+			if change.Key == key || strings.HasPrefix(change.Key, key+".") {
+				matched = append(matched, change)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Registers a subscription, usually from the constructor of a module.
+func (m *Module) Subscribe(s Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscriptions = append(m.subscriptions, &s)
+}
+
+// Subscribe calls apply with the value of key decoded into T after every
+// reload changing it. validate may reject the value, nil accepts every value
+// that decodes.
+// e.g. config.Subscribe(c, "server.allow_origins", validateOrigins, m.setAllowOrigins)
+func Subscribe[T any](m *Module, key string, validate func(T) error, apply func(T)) {
+	decode := func(v *viper.Viper) (T, error) {
+		var value T
+		if err := v.UnmarshalKey(key, &value); err != nil {
+			return value, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return value, nil
+	}
+
+	m.Subscribe(Subscription{
+		Keys: []string{key},
+		Validate: func(next *viper.Viper) error {
+			value, err := decode(next)
+			if err != nil {
+				return err
+			}
+			if validate == nil {
+				return nil
+			}
+			if err := validate(value); err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			return nil
+		},
+		Apply: func(next *viper.Viper, _ []Change) {
+			// decoded by Validate already, next does not change
+			value, _ := decode(next)
+			apply(value)
+		},
+	})
+}
+
+// Reload reads the config files again and applies them when every
+// subscription affected by the changes accepts them. Without changes to the
+// effective config nothing happens. The files are loaded and validated apart
+// from the live config, which only changes once they are accepted.
+func (m *Module) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files, err := readConfigFiles()
+	if err != nil {
+		return err
+	}
+	if files.base == nil {
+		return fmt.Errorf("%s.%s not found", baseConfigName, setup.configFileType)
+	}
+	next, err := candidate(files)
+	if err != nil {
+		return err
+	}
+
+	live.RLock()
+	before := settings(viper.GetViper())
+	live.RUnlock()
+
+	changes := diff(before, settings(next))
+	if len(changes) == 0 {
+		// values may have moved between files, their sources changed
+		return m.apply(files)
+	}
+
+	type pending struct {
+		subscription *Subscription
+		changes      []Change
+	}
+	var affected []pending
+	var errs []error
+	for _, s := range m.subscriptions {
+		matched := matching(changes, s.Keys)
+		if len(matched) == 0 {
+			continue
+		}
+		affected = append(affected, pending{subscription: s, changes: matched})
+		if s.Validate != nil {
+			if err := s.Validate(next); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if err := m.apply(files); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if IsSecret(change.Key) {
+			m.logger.Info("Config changed", zap.String("key", change.Key))
+			continue
+		}
+		m.logger.Info("Config changed", zap.String("key", change.Key), zap.Any("old", change.Old), zap.Any("new", change.New))
+	}
+	for _, p := range affected {
+		if p.subscription.Apply != nil {
+			p.subscription.Apply(next, p.changes)
+		}
+	}
+
+	m.logger.Info("Config reloaded", zap.Int("changes", len(changes)), zap.Int("subscriptions", len(affected)))
+	return nil
+}
+
+// Settings returns the effective config as of the start or the last reload,
+// see Effective. Safe to call from request handlers.
+func (m *Module) Settings() []Setting {
+	if settings := m.snapshot.Load(); settings != nil {
+		return *settings
+	}
+	return Effective()
+}
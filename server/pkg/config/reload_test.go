@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+		want   []Change
+	}{
+		{
+			name:   "unchanged",
+			before: map[string]any{"server.port": 3001},
+			after:  map[string]any{"server.port": 3001},
+		},
+		{
+			name:   "changed",
+			before: map[string]any{"server.port": 3001},
+			after:  map[string]any{"server.port": 3002},
+			want:   []Change{{Key: "server.port", Old: 3001, New: 3002}},
+		},
+		{
+			name:   "added and removed",
+			before: map[string]any{"b": "old"},
+			after:  map[string]any{"a": "new"},
+			want:   []Change{{Key: "a", New: "new"}, {Key: "b", Old: "old"}},
+		},
+		{
+			name:   "lists compare by value",
+			before: map[string]any{"sinks": []any{"stdout"}},
+			after:  map[string]any{"sinks": []any{"stdout"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diff(test.before, test.after)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatching(t *testing.T) {
+	changes := []Change{
+		{Key: "global.log_level"},
+		{Key: "server.allow_origins"},
+		{Key: "server.allow_originsx"},
+		{Key: "serverx.port"},
+	}
+	tests := []struct {
+		keys []string
+		want []string
+	}{
+		{[]string{"server"}, []string{"server.allow_origins", "server.allow_originsx"}},
+		{[]string{"server.allow_origins"}, []string{"server.allow_origins"}},
+		{[]string{"Global.Log_Level"}, []string{"global.log_level"}},
+		{[]string{"global", "serverx"}, []string{"global.log_level", "serverx.port"}},
+		{[]string{"database"}, nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, change := range matching(changes, test.keys) {
+			got = append(got, change.Key)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%v matched %v, want %v", test.keys, got, test.want)
+		}
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(origins string) {
+		t.Helper()
+		content := fmt.Sprintf("server:\n  allow_origins: %q\n", origins)
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("*")
+	SetUpConfig("", "yaml", dir)
+
+	m := &Module{config: &Config{}, logger: zap.NewNop()}
+	var applied []string
+	Subscribe(m, "server.allow_origins", func(origins string) error {
+		if origins == "invalid" {
+			return errors.New("not an origin")
+		}
+		return nil
+	}, func(origins string) {
+		applied = append(applied, origins)
+	})
+
+	write("invalid")
+	if err := m.Reload(); err == nil {
+		t.Fatal("Reload accepted a value the subscription rejected")
+	}
+	if got := viper.GetString("server.allow_origins"); got != "*" {
+		t.Errorf("rejected reload left server.allow_origins = %q, want *", got)
+	}
+	if len(applied) != 0 {
+		t.Errorf("rejected reload applied %v", applied)
+	}
+
+	write("https://funcedup.com")
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetString("server.allow_origins"); got != "https://funcedup.com" {
+		t.Errorf("server.allow_origins = %q after reload", got)
+	}
+	if fmt.Sprint(applied) != "[https://funcedup.com]" {
+		t.Errorf("applied %v, want the reloaded origins once", applied)
+	}
+
+	// values set in code stay on top of the reloaded files
+	Set("server.allow_origins", "https://override.funcedup.com")
+	write("https://other.funcedup.com")
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetString("server.allow_origins"); got != "https://override.funcedup.com" {
+		t.Errorf("server.allow_origins = %q, want the override", got)
+	}
+	if len(applied) != 1 {
+		t.Errorf("a reload hidden by an override applied %v", applied[1:])
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Prefixes of values Load resolves instead of using them as they are.
+// e.g. database.password: "file:///run/secrets/db_password"
+//
+//	SERVER_JWT_SECRET=env:JWT_SIGNING_KEY
+const (
+	FilePrefix = "file://"
+	EnvPrefix  = "env:"
+)
+
+// build_env of production deployments, secrets must not hold their defaults,
+// see IsProduction
+const ProductionEnvironment = "production"
+
+// shown instead of secret values in logs and reports
+const Redacted = "<redacted>"
+
+var (
+	secretKeysMu sync.RWMutex
+	// keys of fields tagged `config:"secret"`, see Load
+	secretKeys = make(map[string]bool)
+)
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Resolve returns the content of the file of a file:// value, without the
+// trailing newline, or the variable of an env: value. Other values are
+// returned as they are.
+func Resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, FilePrefix):
+		path := strings.TrimPrefix(value, FilePrefix)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case strings.HasPrefix(value, EnvPrefix):
+		name := strings.TrimPrefix(value, EnvPrefix)
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// IsProduction reports whether build_env is production or the prod profile is
+// active.
+func IsProduction() bool {
+	live.RLock()
+	defer live.RUnlock()
+
+	return strings.EqualFold(viper.GetString("build_env"), ProductionEnvironment) || setup.profile == ProfileProd
+}
+
+// Redact returns Redacted for a set value, so logs still show whether a
+// secret is configured without showing it.
+// e.g. m.logger.Debug("Password", zap.String("password", config.Redact(m.config.Password)))
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// IsSecret reports whether the value of key is never shown, either because a
+// module marked it as a secret or because of its name.
+func IsSecret(key string) bool {
+	key = strings.ToLower(key)
+
+	secretKeysMu.RLock()
+	marked := secretKeys[key]
+	secretKeysMu.RUnlock()
+	if marked {
+		return true
+	}
+
+	for _, part := range []string{"password", "secret", "token", "private_key", "api_key"} {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func markSecret(key string) {
+	secretKeysMu.Lock()
+	defer secretKeysMu.Unlock()
+
+	secretKeys[key] = true
+}
@@ -0,0 +1,370 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"funcedup/pkg/config"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Module struct {
+	config *Config
+	logger *zap.Logger
+	scope  string
+}
+
+type Params struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+}
+
+type Config struct {
+	Issuer     string
+	CookieName string
+	HeaderName string
+
+	Scopes map[Scope]ScopeConfig
+}
+
+// ScopeConfig holds the claim value, signing key and lifetime of one token scope.
+type ScopeConfig struct {
+	Name   string
+	Secret string
+	TTL    time.Duration
+}
+
+// Scope identifies what a token may be used for.
+// Tokens are only valid for the scope they were issued for.
+type Scope int
+
+const (
+	ScopeAuth Scope = iota
+	ScopeEmailConfirmation
+	ScopePasswordReset
+)
+
+// Claims carried by every token issued by the module.
+type Claims struct {
+	Scope string `json:"scope"`
+	gojwt.RegisteredClaims
+}
+
+const (
+	DefaultIssuer     = "funcedup"
+	DefaultCookieName = "jwt"
+	DefaultHeaderName = "jwt"
+	DefaultSecret     = "funcedup-development-secret"
+
+	DefaultAuthScope          = "auth"
+	DefaultEmailScope         = "email_confirmation"
+	DefaultPasswordResetScope = "password_reset"
+
+	DefaultAuthTTL          = 24 * time.Hour
+	DefaultEmailTTL         = 48 * time.Hour
+	DefaultPasswordResetTTL = 30 * time.Minute
+
+	// key the verified claims are stored under in the echo context
+	ContextKeyClaims = "jwt_claims"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrWrongScope   = errors.New("token scope mismatch")
+)
+
+//! MODULE ---------------------------------------------------------------
+
+// Provides the module to the fx framework
+func InjectModule(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) *Module {
+
+			m := &Module{scope: scope}
+			m.config = m.setupConfig(scope)
+			m.logger = m.setupLogger(scope, p)
+
+			return m
+		}),
+		fx.Invoke(func(m *Module, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: m.onStart,
+					OnStop:  m.onStop,
+				},
+			)
+		}),
+	)
+}
+
+// Instantiates new Module without using the fx framework.
+func NewJWT(scope string, logger *zap.Logger) *Module {
+	m := &Module{scope: scope}
+	m.logger = logger.Named("[" + scope + "]")
+	m.config = m.setupConfig(scope)
+
+	m.onStart(context.Background())
+
+	return m
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// settings are the keys of the jwt scope, Config groups them by token scope.
+type settings struct {
+	Issuer     string `mapstructure:"issuer" validate:"required"`
+	CookieName string `mapstructure:"cookie_name" validate:"required"`
+	HeaderName string `mapstructure:"header_name" validate:"required"`
+	Secret     string `mapstructure:"secret" config:"secret" validate:"required"`
+
+	AuthScope          string `mapstructure:"jwt_auth_scope" validate:"required"`
+	EmailScope         string `mapstructure:"jwt_email_scope" validate:"required"`
+	PasswordResetScope string `mapstructure:"jwt_pw_reset_scope" validate:"required"`
+
+	// per scope secrets fall back to the shared secret
+	AuthSecret          string `mapstructure:"auth_secret" config:"secret"`
+	EmailSecret         string `mapstructure:"email_secret" config:"secret"`
+	PasswordResetSecret string `mapstructure:"pw_reset_secret" config:"secret"`
+
+	AuthTTL          time.Duration `mapstructure:"auth_ttl" validate:"gt=0"`
+	EmailTTL         time.Duration `mapstructure:"email_ttl" validate:"gt=0"`
+	PasswordResetTTL time.Duration `mapstructure:"pw_reset_ttl" validate:"gt=0"`
+}
+
+func (m *Module) setupConfig(scope string) *Config {
+	s := &settings{
+		Issuer:     DefaultIssuer,
+		CookieName: DefaultCookieName,
+		HeaderName: DefaultHeaderName,
+		Secret:     DefaultSecret,
+
+		AuthScope:          DefaultAuthScope,
+		EmailScope:         DefaultEmailScope,
+		PasswordResetScope: DefaultPasswordResetScope,
+
+		AuthTTL:          DefaultAuthTTL,
+		EmailTTL:         DefaultEmailTTL,
+		PasswordResetTTL: DefaultPasswordResetTTL,
+	}
+	config.Load(scope, s)
+
+	scopeSecret := func(secret string) string {
+		if secret != "" {
+			return secret
+		}
+		return s.Secret
+	}
+
+	return &Config{
+		Issuer:     s.Issuer,
+		CookieName: s.CookieName,
+		HeaderName: s.HeaderName,
+		Scopes: map[Scope]ScopeConfig{
+			ScopeAuth: {
+				Name:   s.AuthScope,
+				Secret: scopeSecret(s.AuthSecret),
+				TTL:    s.AuthTTL,
+			},
+			ScopeEmailConfirmation: {
+				Name:   s.EmailScope,
+				Secret: scopeSecret(s.EmailSecret),
+				TTL:    s.EmailTTL,
+			},
+			ScopePasswordReset: {
+				Name:   s.PasswordResetScope,
+				Secret: scopeSecret(s.PasswordResetSecret),
+				TTL:    s.PasswordResetTTL,
+			},
+		},
+	}
+}
+
+func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+func (m *Module) onStart(context.Context) error {
+	m.logger.Info("Starting jwt module.")
+
+	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
+		m.logConfigurations()
+	}
+
+	for _, sc := range m.config.Scopes {
+		if sc.Secret == DefaultSecret {
+			m.logger.Warn("Using the default jwt secret, set jwt.secret outside of development.", zap.String("scope", sc.Name))
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *Module) onStop(context.Context) error {
+	m.logger.Info("Stopping jwt module.")
+	return nil
+}
+
+func (m *Module) logConfigurations() {
+	m.logger.Debug("----- JWT Configuration -----")
+	m.logger.Debug("Issuer", zap.String("issuer", m.config.Issuer))
+	m.logger.Debug("CookieName", zap.String("cookie_name", m.config.CookieName))
+	m.logger.Debug("HeaderName", zap.String("header_name", m.config.HeaderName))
+	for _, sc := range m.config.Scopes {
+		m.logger.Debug("Scope", zap.String("name", sc.Name), zap.Duration("ttl", sc.TTL), zap.String("secret", config.Redact(sc.Secret)))
+	}
+}
+
+// extracts a token from the Authorization bearer header, the configured
+// header or the configured cookie, in that order.
+func (m *Module) tokenFromRequest(c echo.Context) string {
+	if h := c.Request().Header.Get(echo.HeaderAuthorization); h != "" {
+		if token, found := strings.CutPrefix(h, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	if h := c.Request().Header.Get(m.config.HeaderName); h != "" {
+		return strings.TrimSpace(h)
+	}
+	if cookie, err := c.Cookie(m.config.CookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Signs a token for the given scope and subject (usually a user ID).
+// Returns the token together with its expiry.
+func (m *Module) Sign(scope Scope, subject string) (string, time.Time, error) {
+	sc, ok := m.config.Scopes[scope]
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("unknown token scope: %d", scope)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(sc.TTL)
+	claims := Claims{
+		Scope: sc.Name,
+		RegisteredClaims: gojwt.RegisteredClaims{
+			Issuer:    m.config.Issuer,
+			Subject:   subject,
+			IssuedAt:  gojwt.NewNumericDate(now),
+			NotBefore: gojwt.NewNumericDate(now),
+			ExpiresAt: gojwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte(sc.Secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verifies the token signature, expiry, issuer and scope.
+func (m *Module) Verify(scope Scope, token string) (*Claims, error) {
+	sc, ok := m.config.Scopes[scope]
+	if !ok {
+		return nil, fmt.Errorf("unknown token scope: %d", scope)
+	}
+
+	var claims Claims
+	_, err := gojwt.ParseWithClaims(token, &claims,
+		func(t *gojwt.Token) (interface{}, error) {
+			return []byte(sc.Secret), nil
+		},
+		gojwt.WithValidMethods([]string{gojwt.SigningMethodHS256.Alg()}),
+		gojwt.WithIssuer(m.config.Issuer),
+		gojwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if claims.Scope != sc.Name {
+		return nil, ErrWrongScope
+	}
+
+	return &claims, nil
+}
+
+// Echo middleware that verifies the auth scoped token of the request, if any,
+// and stores the claims under ContextKeyClaims.
+// Requests without a valid token continue anonymously, use RequireClaims or
+// server.RequireAuth to reject them.
+func (m *Module) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := m.tokenFromRequest(c)
+			if token == "" {
+				return next(c)
+			}
+
+			claims, err := m.Verify(ScopeAuth, token)
+			if err != nil {
+				m.logger.Debug("Ignoring invalid token", zap.Error(err))
+				return next(c)
+			}
+
+			c.Set(ContextKeyClaims, claims)
+			return next(c)
+		}
+	}
+}
+
+// Echo middleware rejecting requests that did not carry a valid auth token.
+func RequireClaims(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := GetClaims(c); !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		}
+		return next(c)
+	}
+}
+
+// Returns the verified claims stored on the echo context.
+func GetClaims(c echo.Context) (*Claims, bool) {
+	claims, ok := c.Get(ContextKeyClaims).(*Claims)
+	return claims, ok
+}
+
+// Sets the auth token as an http only cookie.
+func (m *Module) SetCookie(c echo.Context, token string, expiresAt time.Time, secure bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Expires the auth token cookie.
+func (m *Module) ClearCookie(c echo.Context, secure bool) {
+	c.SetCookie(&http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
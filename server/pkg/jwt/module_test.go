@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func testModule(issuer string, emailSecret string) *Module {
+	return &Module{
+		logger: zap.NewNop(),
+		config: &Config{
+			Issuer:     issuer,
+			CookieName: DefaultCookieName,
+			HeaderName: DefaultHeaderName,
+			Scopes: map[Scope]ScopeConfig{
+				ScopeAuth:              {Name: DefaultAuthScope, Secret: "auth-secret", TTL: time.Hour},
+				ScopeEmailConfirmation: {Name: DefaultEmailScope, Secret: emailSecret, TTL: time.Hour},
+				ScopePasswordReset:     {Name: DefaultPasswordResetScope, Secret: "auth-secret", TTL: -time.Minute},
+			},
+		},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := testModule(DefaultIssuer, "email-secret")
+
+	token, expiresAt, err := m.Sign(ScopeAuth, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.Verify(ScopeAuth, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.Scope != DefaultAuthScope || claims.Issuer != DefaultIssuer {
+		t.Errorf("got subject %q, scope %q, issuer %q", claims.Subject, claims.Scope, claims.Issuer)
+	}
+	if !claims.ExpiresAt.Time.Equal(expiresAt.Truncate(time.Second)) {
+		t.Errorf("expires at %v, want %v", claims.ExpiresAt.Time, expiresAt)
+	}
+
+	if _, err := m.Verify(ScopeAuth, token+"x"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("tampered token: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := m.Verify(Scope(42), token); err == nil {
+		t.Error("unknown scope: expected an error")
+	}
+
+	expired, _, err := m.Sign(ScopePasswordReset, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ScopePasswordReset, expired); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyRejectsOtherScopes(t *testing.T) {
+	// the password reset scope shares the auth secret, only the claim tells them apart
+	m := testModule(DefaultIssuer, "email-secret")
+	m.config.Scopes[ScopePasswordReset] = ScopeConfig{Name: DefaultPasswordResetScope, Secret: "auth-secret", TTL: time.Hour}
+
+	reset, _, err := m.Sign(ScopePasswordReset, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ScopeAuth, reset); !errors.Is(err, ErrWrongScope) {
+		t.Errorf("shared secret: got %v, want ErrWrongScope", err)
+	}
+
+	email, _, err := m.Sign(ScopeEmailConfirmation, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ScopeAuth, email); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("separate secret: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyRejectsOtherIssuers(t *testing.T) {
+	other := testModule("someone-else", "email-secret")
+	token, _, err := other.Sign(ScopeAuth, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := testModule(DefaultIssuer, "email-secret")
+	if _, err := m.Verify(ScopeAuth, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyRequiresExpiry(t *testing.T) {
+	m := testModule(DefaultIssuer, "email-secret")
+	claims := Claims{
+		Scope:            DefaultAuthScope,
+		RegisteredClaims: gojwt.RegisteredClaims{Issuer: DefaultIssuer, Subject: "user-1"},
+	}
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte("auth-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Verify(ScopeAuth, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := testModule(DefaultIssuer, "email-secret")
+	auth, _, err := m.Sign(ScopeAuth, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, _, err := m.Sign(ScopeEmailConfirmation, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		subject string
+	}{
+		{"bearer", echo.HeaderAuthorization, "Bearer " + auth, "user-1"},
+		{"header", DefaultHeaderName, auth, "user-1"},
+		{"other scope", echo.HeaderAuthorization, "Bearer " + email, ""},
+		{"anonymous", "", "", ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+
+		var subject string
+		handler := m.Middleware()(func(c echo.Context) error {
+			if claims, ok := GetClaims(c); ok {
+				subject = claims.Subject
+			}
+			return nil
+		})
+		if err := handler(c); err != nil {
+			t.Fatal(err)
+		}
+		if subject != test.subject {
+			t.Errorf("%s: got subject %q, want %q", test.name, subject, test.subject)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type fieldsContextKey struct{}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns a copy of ctx carrying fields in addition to the ones already on it.
+// Middleware use it for request scoped values, e.g. the request ID.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing := contextFields(ctx)
+	combined := make([]zap.Field, 0, len(existing)+len(fields))
+	combined = append(combined, existing...)
+	combined = append(combined, fields...)
+	return context.WithValue(ctx, fieldsContextKey{}, combined)
+}
+
+// Returns the fields stored on ctx by WithFields, followed by the trace and
+// span ID of the span on ctx if any.
+func ContextFields(ctx context.Context) []zap.Field {
+	return slices.Concat(contextFields(ctx), TraceFields(ctx))
+}
+
+// Returns a child of base carrying the fields of ctx.
+// e.g. logger.Ctx(c.Request().Context(), d.logger).Error("Error creating note", zap.Error(err))
+func Ctx(ctx context.Context, base *zap.Logger) *zap.Logger {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return base
+	}
+	return base.With(fields...)
+}
+
+// Returns a child of the global logger carrying the fields of ctx.
+func FromContext(ctx context.Context) *zap.Logger {
+	return Ctx(ctx, zap.L())
+}
+
+// Returns trace_id and span_id fields for the span on ctx, none when ctx is
+// not part of a sampled trace.
+func TraceFields(ctx context.Context) []zap.Field {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	}
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func contextFields(ctx context.Context) []zap.Field {
+	fields, _ := ctx.Value(fieldsContextKey{}).([]zap.Field)
+	return fields
+}
@@ -0,0 +1,252 @@
+package logger
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels holds the level every entry has to pass, the global level started
+// from global.log_level and overrides for single named loggers. Changes apply
+// to all loggers derived from the module logger without a restart.
+// e.g. levels.SetOverride("[database]", zap.DebugLevel, 10*time.Minute)
+type Levels struct {
+	global  zap.AtomicLevel
+	initial zapcore.Level
+
+	mu          sync.RWMutex
+	globalTimer *time.Timer
+	globalUntil time.Time
+	overrides   map[string]*levelOverride
+
+	// avoids the lock on every entry while there are no overrides
+	hasOverrides atomic.Bool
+}
+
+type levelOverride struct {
+	level zapcore.Level
+	until time.Time
+	timer *time.Timer
+}
+
+// LevelState is a snapshot of Levels, see Levels.State.
+type LevelState struct {
+	Level     string          `json:"level"`
+	Default   string          `json:"default"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+	Overrides []LevelOverride `json:"overrides"`
+}
+
+type LevelOverride struct {
+	Logger    string     `json:"logger"`
+	Level     string     `json:"level"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func newLevels(global zap.AtomicLevel) *Levels {
+	return &Levels{
+		global:    global,
+		initial:   global.Level(),
+		overrides: make(map[string]*levelOverride),
+	}
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns the current global level.
+func (l *Levels) Level() zapcore.Level {
+	return l.global.Level()
+}
+
+// Sets the global level, reverting to global.log_level after ttl.
+// A ttl of zero keeps the level until it is changed again.
+func (l *Levels) SetLevel(level zapcore.Level, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.globalTimer != nil {
+		l.globalTimer.Stop()
+		l.globalTimer = nil
+	}
+	l.globalUntil = time.Time{}
+	l.global.SetLevel(level)
+
+	if ttl <= 0 || level == l.initial {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// replaced by a later call in the meantime
+		if l.globalTimer != timer {
+			return
+		}
+		l.globalTimer = nil
+		l.globalUntil = time.Time{}
+		l.global.SetLevel(l.initial)
+	})
+	l.globalTimer = timer
+	l.globalUntil = time.Now().Add(ttl)
+}
+
+// Replaces the level SetLevel reverts to, e.g. after global.log_level was
+// reloaded. The global level follows unless a temporary level is active.
+func (l *Levels) SetDefault(level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.initial = level
+	if l.globalTimer == nil {
+		l.global.SetLevel(level)
+	}
+}
+
+// Sets the level of the logger with the given name and of its children,
+// removed again after ttl. A ttl of zero keeps the override until it is
+// removed. Names are the ones shown in the log, e.g. "[database]".
+func (l *Levels) SetOverride(name string, level zapcore.Level, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeOverride(name)
+
+	override := &levelOverride{level: level}
+	if ttl > 0 {
+		override.until = time.Now().Add(ttl)
+		override.timer = time.AfterFunc(ttl, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.overrides[name] == override {
+				l.removeOverride(name)
+			}
+		})
+	}
+	l.overrides[name] = override
+	l.hasOverrides.Store(true)
+}
+
+// Removes the override of the logger with the given name, if any.
+func (l *Levels) RemoveOverride(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeOverride(name)
+}
+
+// Returns the global level and all overrides.
+func (l *Levels) State() LevelState {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	state := LevelState{
+		Level:     l.global.Level().String(),
+		Default:   l.initial.String(),
+		ExpiresAt: timeOrNil(l.globalUntil),
+		Overrides: make([]LevelOverride, 0, len(l.overrides)),
+	}
+	for name, override := range l.overrides {
+		state.Overrides = append(state.Overrides, LevelOverride{
+			Logger:    name,
+			Level:     override.level.String(),
+			ExpiresAt: timeOrNil(override.until),
+		})
+	}
+	slices.SortFunc(state.Overrides, func(a, b LevelOverride) int {
+		return strings.Compare(a.Logger, b.Logger)
+	})
+	return state
+}
+
+// Reports whether an entry of the named logger at level is written.
+// The most specific override wins, without one the global level applies.
+func (l *Levels) Enabled(name string, level zapcore.Level) bool {
+	if !l.hasOverrides.Load() {
+		return l.global.Enabled(level)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// "[server].http" is matched by "[server].http", then "[server]"
+	for {
+		if override, ok := l.overrides[name]; ok {
+			return override.level.Enabled(level)
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return l.global.Enabled(level)
+		}
+		name = name[:i]
+	}
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// minLevel is the lowest level any logger writes at, zap asks for it before
+// the name of the logger is known.
+func (l *Levels) minLevel() zapcore.Level {
+	level := l.global.Level()
+	if !l.hasOverrides.Load() {
+		return level
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, override := range l.overrides {
+		level = min(level, override.level)
+	}
+	return level
+}
+
+// removeOverride expects l.mu to be held.
+func (l *Levels) removeOverride(name string) {
+	if override, ok := l.overrides[name]; ok {
+		if override.timer != nil {
+			override.timer.Stop()
+		}
+		delete(l.overrides, name)
+	}
+	l.hasOverrides.Store(len(l.overrides) > 0)
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// levelCore filters entries by Levels before they reach the sinks, which
+// only apply their own static level.
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return level >= c.levels.minLevel() && c.Core.Enabled(level)
+}
+
+// Level is used by zap.Logger.Level and zapcore.LevelOf.
+func (c *levelCore) Level() zapcore.Level {
+	return c.levels.minLevel()
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Enabled(entry.LoggerName, entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
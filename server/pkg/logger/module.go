@@ -1,8 +1,16 @@
 package logger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/util"
 
 	"github.com/spf13/viper"
 	"go.uber.org/fx"
@@ -13,9 +21,41 @@ import (
 // to be provided to the fx framework
 var logger *zap.Logger
 
+// Config of the logger scope, see sinks.go for the sink settings.
+type Config struct {
+	Encoding string `mapstructure:"encoding" validate:"oneofci=console json"`
+	Color    bool   `mapstructure:"color"`
+	Sinks    []SinkConfig
+
+	Sampling           bool          `mapstructure:"sampling.enabled"`
+	SamplingTick       time.Duration `mapstructure:"sampling.tick" validate:"gt=0"`
+	SamplingInitial    int           `mapstructure:"sampling.initial" validate:"gte=0"`
+	SamplingThereafter int           `mapstructure:"sampling.thereafter" validate:"gte=0"`
+}
+
+// keys of the global scope read by the logger, values besides the zap levels
+// are used by other modules, e.g. dev and prod by the request logger
+type globalConfig struct {
+	LogLevel string `mapstructure:"log_level" validate:"oneofci=debug info warn error dpanic panic fatal dev prod"`
+}
+
+// Log encodings selectable with logger.encoding and per sink.
+const (
+	EncodingConsole = "console"
+	EncodingJSON    = "json"
+)
+
 // default values
 const (
 	DefaultSystemLogLevel = zap.InfoLevel
+
+	DefaultEncoding = EncodingConsole
+	DefaultColor    = true
+
+	DefaultSampling           = false
+	DefaultSamplingTick       = time.Second
+	DefaultSamplingInitial    = 100
+	DefaultSamplingThereafter = 100
 )
 
 //! MODULE ---------------------------------------------------------------
@@ -23,24 +63,91 @@ const (
 // Provides the logger to the fx framework
 func InjectModule(scope string) fx.Option {
 	return fx.Options(
-		fx.Provide(setupLogger),
+		fx.Provide(func() (*zap.Logger, *Levels, error) {
+			return setupLogger(scope)
+		}),
+		fx.Invoke(func(l *zap.Logger, lc fx.Lifecycle) {
+			lc.Append(fx.Hook{
+				OnStop: func(context.Context) error {
+					return syncLogger(l)
+				},
+			})
+		}),
+		fx.Invoke(func(p reloadParams) {
+			if p.Config != nil {
+				subscribeConfig(p.Config, p.Levels)
+			}
+		}),
 	)
 }
 
 // Instantiate the logger without using the fx framework
 func NewLogger() *zap.Logger {
-	return setupLogger()
+	l, _, err := setupLogger("logger")
+	if err != nil {
+		// the config is broken, fall back to the defaults to report it
+		l = zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(NewCustomEncoderConfig()), zapcore.Lock(os.Stdout), DefaultSystemLogLevel))
+		l.Error("Invalid logger configuration", zap.Error(err))
+	}
+	return l
 }
 
 // ! INTERNAL ---------------------------------------------------------------
 
-func setupLogger() *zap.Logger {
+func setupConfig(scope string) (*Config, error) {
+	config.Load("global", &globalConfig{LogLevel: DefaultSystemLogLevel.String()})
+
+	c := &Config{
+		Encoding: DefaultEncoding,
+		Color:    DefaultColor,
+
+		Sampling:           DefaultSampling,
+		SamplingTick:       DefaultSamplingTick,
+		SamplingInitial:    DefaultSamplingInitial,
+		SamplingThereafter: DefaultSamplingThereafter,
+	}
+	config.Load(scope, c)
+	c.Encoding = strings.ToLower(c.Encoding)
+
+	// the logger is needed to report anything, sinks fail right away
+	if err := viper.UnmarshalKey(util.GetConfigPath(scope, "sinks"), &c.Sinks); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", util.GetConfigPath(scope, "sinks"), err)
+	}
+	// without sinks everything goes to stdout, as before sinks existed
+	if len(c.Sinks) == 0 {
+		c.Sinks = []SinkConfig{{Type: SinkStdout}}
+	}
+
+	return c, nil
+}
+
+func setupLogger(scope string) (*zap.Logger, *Levels, error) {
+	config, err := setupConfig(scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	logLevel := setupLevel()
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(NewCustomEncoderConfig()),
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout)),
-		logLevel,
-	)
+	levels := newLevels(logLevel)
+
+	cores := make([]zapcore.Core, 0, len(config.Sinks))
+	for i, sink := range config.Sinks {
+		core, err := newSinkCore(sink, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s[%d]: %w", util.GetConfigPath(scope, "sinks"), i, err)
+		}
+		cores = append(cores, core)
+	}
+
+	core := zapcore.NewTee(cores...)
+	// only the first `initial` entries with the same level and message per
+	// tick are logged, then every `thereafter`th
+	if config.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, config.SamplingTick, config.SamplingInitial, config.SamplingThereafter)
+	}
+	// the global level and overrides are checked before sampling, so filtered
+	// entries do not count towards it
+	core = &levelCore{Core: core, levels: levels}
 
 	if viper.GetString("global.log_level") == zap.DebugLevel.String() || viper.GetString("global.log_level") == zap.DebugLevel.CapitalString() {
 		logger = zap.New(core, zap.AddCaller(), zap.Development())
@@ -52,13 +159,35 @@ func setupLogger() *zap.Logger {
 
 	logger.Named("[logger]").Info(fmt.Sprintf("System log level is set to \"%s\"\n", logLevel.Level().CapitalString()))
 
-	return logger
+	return logger, levels, nil
+}
+
+// reloadParams are optional, commands without the config module can not
+// reload their config.
+type reloadParams struct {
+	fx.In
+	Levels *Levels
+	Config *config.Module `optional:"true"`
+}
+
+// subscribeConfig applies a changed global.log_level, unless an admin changed
+// the level temporarily, then it applies once the change reverts.
+func subscribeConfig(c *config.Module, levels *Levels) {
+	config.Subscribe(c, "global.log_level", nil, func(value string) {
+		levels.SetDefault(parseLevel(value))
+	})
 }
 
 func setupLevel() zap.AtomicLevel {
+	return zap.NewAtomicLevelAt(parseLevel(viper.GetString("global.log_level")))
+}
+
+// parseLevel maps global.log_level to a zap level, unknown values such as
+// dev and prod are treated as info.
+func parseLevel(value string) zapcore.Level {
 	var logLevel zapcore.Level
 
-	switch viper.GetString("global.log_level") {
+	switch value {
 	case zap.DebugLevel.String(), zap.DebugLevel.CapitalString():
 		logLevel = zap.DebugLevel
 	case zap.InfoLevel.String(), zap.InfoLevel.CapitalString():
@@ -77,7 +206,17 @@ func setupLevel() zap.AtomicLevel {
 		logLevel = DefaultSystemLogLevel
 	}
 
-	return zap.NewAtomicLevelAt(logLevel)
+	return logLevel
+}
+
+// syncLogger flushes buffered entries, stdout and stderr can not be synced
+// on every platform which is not worth reporting.
+func syncLogger(l *zap.Logger) error {
+	err := l.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+		return nil
+	}
+	return err
 }
 
 func NewCustomEncoderConfig() zapcore.EncoderConfig {
@@ -96,3 +235,13 @@ func NewCustomEncoderConfig() zapcore.EncoderConfig {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 }
+
+// Encoder config for machine readable output, e.g. the log pipeline.
+// Levels are lower case and times RFC 3339 with nanoseconds.
+func NewJSONEncoderConfig() zapcore.EncoderConfig {
+	config := NewCustomEncoderConfig()
+	config.EncodeLevel = zapcore.LowercaseLevelEncoder
+	config.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	config.EncodeDuration = zapcore.StringDurationEncoder
+	return config
+}
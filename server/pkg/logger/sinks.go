@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Destinations selectable with logger.sinks[].type.
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+)
+
+// SinkConfig is one entry of logger.sinks.
+// e.g. {type: file, path: ./logs/server.log, level: warn, encoding: json, max_size_mb: 100}
+type SinkConfig struct {
+	Type string `mapstructure:"type"`
+
+	// minimum level of the sink, entries also need to pass the global level
+	// or the override of their logger, see Levels
+	Level string `mapstructure:"level"`
+	// console or json, defaults to logger.encoding
+	Encoding string `mapstructure:"encoding"`
+
+	// file sinks only, rotated once max_size_mb is reached
+	Path       string `mapstructure:"path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+	MaxBackups int    `mapstructure:"max_backups"`
+	Compress   bool   `mapstructure:"compress"`
+}
+
+const (
+	DefaultFileMaxSizeMB  = 100
+	DefaultFileMaxAgeDays = 7
+	DefaultFileMaxBackups = 5
+)
+
+// ! INTERNAL ---------------------------------------------------------------
+
+// newSinkCore returns a core writing entries at or above the level of the
+// sink, the global level is applied in front of all sinks by levelCore.
+func newSinkCore(sink SinkConfig, config *Config) (zapcore.Core, error) {
+	var writer zapcore.WriteSyncer
+	terminal := true
+
+	switch strings.ToLower(sink.Type) {
+	case SinkStdout, "":
+		writer = zapcore.Lock(os.Stdout)
+	case SinkStderr:
+		writer = zapcore.Lock(os.Stderr)
+	case SinkFile:
+		if sink.Path == "" {
+			return nil, fmt.Errorf("file sink without path")
+		}
+		writer = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   sink.Path,
+			MaxSize:    orDefault(sink.MaxSizeMB, DefaultFileMaxSizeMB),
+			MaxAge:     orDefault(sink.MaxAgeDays, DefaultFileMaxAgeDays),
+			MaxBackups: orDefault(sink.MaxBackups, DefaultFileMaxBackups),
+			Compress:   sink.Compress,
+		})
+		terminal = false
+	default:
+		return nil, fmt.Errorf("unknown sink type %q, expected %s, %s or %s", sink.Type, SinkStdout, SinkStderr, SinkFile)
+	}
+
+	encoder, err := newEncoder(sink.Encoding, config, terminal)
+	if err != nil {
+		return nil, err
+	}
+
+	sinkLevel := zapcore.DebugLevel
+	if sink.Level != "" {
+		sinkLevel, err = zapcore.ParseLevel(sink.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return zapcore.NewCore(encoder, writer, sinkLevel), nil
+}
+
+// newEncoder only colors console output written to a terminal stream.
+func newEncoder(encoding string, config *Config, terminal bool) (zapcore.Encoder, error) {
+	if encoding == "" {
+		encoding = config.Encoding
+	}
+
+	switch strings.ToLower(encoding) {
+	case EncodingConsole:
+		encoderConfig := NewCustomEncoderConfig()
+		if !config.Color || !terminal {
+			encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(encoderConfig), nil
+	case EncodingJSON:
+		return zapcore.NewJSONEncoder(NewJSONEncoderConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q, expected %s or %s", encoding, EncodingConsole, EncodingJSON)
+	}
+}
+
+func orDefault(value int, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// key the start of a statement is stored under, see gorm.DB.InstanceSet
+const startKey = "metrics:start"
+
+type dbMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newDBMetrics(namespace string) *dbMetrics {
+	labels := []string{"operation", "table"}
+
+	return &dbMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Duration of GORM statements by operation and table.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_query_errors_total",
+			Help:      "Failed GORM statements by operation and table, not found is not an error.",
+		}, labels),
+	}
+}
+
+func (d *dbMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{d.duration, d.errors}
+}
+
+// registerCallbacks times every statement around the gorm callback executing
+// it, hooks and transactions are not included.
+func (d *dbMetrics) registerCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", d.before),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", d.after("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", d.before),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", d.after("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", d.before),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", d.after("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", d.before),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", d.after("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", d.before),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", d.after("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", d.before),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", d.after("raw")),
+	)
+}
+
+func (d *dbMetrics) before(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (d *dbMetrics) after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		d.duration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			d.errors.WithLabelValues(operation, table).Inc()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"funcedup/pkg/apperr"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// route label of requests that did not match any route, so that scanners do
+// not create a series per probed path
+const unmatchedRoute = "unmatched"
+
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+func newHTTPMetrics(namespace string) *httpMetrics {
+	labels := []string{"method", "route", "status"}
+
+	return &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template and status.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being served.",
+		}),
+	}
+}
+
+func (h *httpMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{h.requests, h.duration, h.inFlight}
+}
+
+// middleware records every request except scrapes of metricsPath.
+func (h *httpMetrics) middleware(metricsPath string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Path() == metricsPath {
+				return next(c)
+			}
+
+			h.inFlight.Inc()
+			defer h.inFlight.Dec()
+
+			start := time.Now()
+			err := next(c)
+
+			// the error handler only writes the response once the chain
+			// returned, take the status it is going to use
+			status := c.Response().Status
+			if err != nil {
+				status = apperr.From(err).Status
+			}
+
+			route := c.Path()
+			if route == "" || route == "/*" && status == http.StatusNotFound {
+				route = unmatchedRoute
+			}
+
+			values := []string{c.Request().Method, route, strconv.Itoa(status)}
+			h.requests.WithLabelValues(values...).Inc()
+			h.duration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"funcedup/pkg/apperr"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMiddlewareRouteLabel(t *testing.T) {
+	h := newHTTPMetrics("test")
+	e := echo.New()
+	e.Use(h.middleware("/metrics"))
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.GET("/metrics", ok)
+	e.GET("/api/v1/users/:id", ok)
+	e.GET("/api/v1/missing", func(c echo.Context) error { return apperr.NotFound("") })
+
+	requests := []string{
+		"/api/v1/users/1",
+		"/api/v1/users/2",
+		"/api/v1/missing",
+		"/wp-login.php",
+		"/.env",
+		"/metrics",
+	}
+	for _, path := range requests {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		// one series per route template, not per path
+		{"/api/v1/users/:id", "200", 2},
+		// the status comes from the returned error, not the unwritten response
+		{"/api/v1/missing", "404", 1},
+		// probed paths share a single series
+		{unmatchedRoute, "404", 2},
+	}
+	for _, test := range tests {
+		got := testutil.ToFloat64(h.requests.WithLabelValues(http.MethodGet, test.route, test.status))
+		if got != test.want {
+			t.Errorf("%s %s: got %v requests, want %v", test.route, test.status, got, test.want)
+		}
+	}
+
+	// scrapes are not recorded
+	if got := testutil.CollectAndCount(h.requests); got != len(tests) {
+		t.Errorf("got %d series, want %d", got, len(tests))
+	}
+}
+
+// With the frontend served under a catch-all route, paths it does not know
+// still must not each get a series.
+func TestHTTPMiddlewareCatchAll(t *testing.T) {
+	h := newHTTPMetrics("test")
+	e := echo.New()
+	e.Use(h.middleware("/metrics"))
+
+	e.GET("/*", func(c echo.Context) error {
+		if c.Param("*") == "index.html" {
+			return c.NoContent(http.StatusOK)
+		}
+		return echo.ErrNotFound
+	})
+
+	for _, path := range []string{"/index.html", "/wp-login.php", "/.env"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(h.requests.WithLabelValues(http.MethodGet, "/*", "200")); got != 1 {
+		t.Errorf("served file: got %v requests, want 1", got)
+	}
+	if got := testutil.ToFloat64(h.requests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")); got != 2 {
+		t.Errorf("missing files: got %v requests, want 2", got)
+	}
+}
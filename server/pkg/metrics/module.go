@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Module struct {
+	config   *Config
+	logger   *zap.Logger
+	scope    string
+	params   Params
+	registry *prometheus.Registry
+
+	http *httpMetrics
+	db   *dbMetrics
+}
+
+type Params struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	Server    *server.Module
+	DB        *pgconn.Module
+}
+
+type Config struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Path      string `mapstructure:"path" validate:"startswith=/"`
+	Namespace string `mapstructure:"namespace"`
+}
+
+const (
+	DefaultEnabled   = true
+	DefaultPath      = "/metrics"
+	DefaultNamespace = "funcedup"
+)
+
+//! MODULE ---------------------------------------------------------------
+
+// Provides the module to the fx framework
+func InjectModule(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) (*Module, error) {
+
+			m := &Module{scope: scope, params: p}
+			m.config = m.setupConfig(scope)
+			m.logger = m.setupLogger(scope, p)
+			m.registry = prometheus.NewRegistry()
+
+			if err := m.setupCollectors(); err != nil {
+				return nil, err
+			}
+
+			return m, nil
+		}),
+		fx.Invoke(func(m *Module, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: m.onStart,
+					OnStop:  m.onStop,
+				},
+			)
+		}),
+	)
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func (m *Module) setupConfig(scope string) *Config {
+	c := &Config{
+		Enabled:   DefaultEnabled,
+		Path:      DefaultPath,
+		Namespace: DefaultNamespace,
+	}
+	config.Load(scope, c)
+
+	return c
+}
+
+func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+// setupCollectors registers the runtime, http and database metrics. Disabled
+// metrics still accept domain counters, they are just never exposed.
+func (m *Module) setupCollectors() error {
+	if !m.config.Enabled {
+		return nil
+	}
+
+	sqlDB, err := m.params.DB.GetDB().DB()
+	if err != nil {
+		return err
+	}
+
+	m.http = newHTTPMetrics(m.config.Namespace)
+	m.db = newDBMetrics(m.config.Namespace)
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		// connection pool stats, e.g. go_sql_open_connections
+		collectors.NewDBStatsCollector(sqlDB, m.params.DB.DBName()),
+	)
+	m.registry.MustRegister(m.http.collectors()...)
+	m.registry.MustRegister(m.db.collectors()...)
+
+	if err := m.db.registerCallbacks(m.params.DB.GetDB()); err != nil {
+		return err
+	}
+
+	e := m.params.Server.GetServer()
+	e.Use(m.http.middleware(m.config.Path))
+	e.GET(m.config.Path, echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})))
+
+	return nil
+}
+
+func (m *Module) onStart(ctx context.Context) error {
+	m.logger.Info("Starting metrics module.")
+
+	if strings.EqualFold(viper.GetString("global.log_level"), "debug") {
+		m.logConfigurations()
+	}
+
+	return nil
+}
+
+func (m *Module) onStop(ctx context.Context) error {
+	m.logger.Info("Stopping metrics module.")
+	return nil
+}
+
+func (m *Module) logConfigurations() {
+	m.logger.Debug("----- Metrics Configuration -----")
+	m.logger.Debug("Enabled", zap.Bool("enabled", m.config.Enabled))
+	m.logger.Debug("Path", zap.String("path", m.config.Path))
+	m.logger.Debug("Namespace", zap.String("namespace", m.config.Namespace))
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns a counter named <namespace>_<name>, registering it on first use.
+// Domains call it once at setup and keep the result.
+// e.g. d.signups = d.params.Metrics.Counter("auth_signups_total", "Accounts created.")
+func (m *Module) Counter(name string, help string, labels ...string) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.config.Namespace,
+		Name:      name,
+		Help:      help,
+	}, labels)
+
+	if err := m.registry.Register(counter); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			if existing, ok := registered.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing
+			}
+		}
+		m.logger.Error("Error registering counter", zap.String("name", name), zap.Error(err))
+	}
+
+	return counter
+}
+
+// Returns the registry metrics are collected in.
+func (m *Module) Registry() *prometheus.Registry {
+	return m.registry
+}
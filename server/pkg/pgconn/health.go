@@ -0,0 +1,63 @@
+package pgconn
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+)
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Verifies that the database is reachable.
+func (m *Module) Ping(ctx context.Context) error {
+	db, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+// Returns a check failing while migrations from fsys are pending or an applied
+// migration has been modified, e.g. while another replica or a `migrate up`
+// job is still running them. Without database.migrate and without a
+// migrations table the schema is not managed by migrations and the check
+// passes.
+func (m *Module) MigrationCheck(fsys fs.FS) func(ctx context.Context) error {
+	migrations, err := LoadMigrations(fsys)
+
+	return func(ctx context.Context) error {
+		if err != nil {
+			return err
+		}
+
+		conn := m.db.WithContext(ctx)
+		if !m.config.Migrate {
+			exists, err := hasMigrationsTable(conn)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return nil
+			}
+		}
+
+		applied, err := m.appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := verifyChecksums(migrations, applied); err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				pending++
+			}
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d pending migrations", pending)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package pgconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"funcedup/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+)
+
+// gormLogger writes GORM logs through zap, with the request ID, user, tenant
+// and trace of the statement context attached, see logger.Ctx.
+//   - failed statements are logged as errors, not found is not a failure
+//   - statements slower than database.slow_query_threshold as warnings
+//   - every other statement at debug level, only with gorm_logger.Info
+type gormLogger struct {
+	logger        *zap.Logger
+	level         gorm_logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func newGormLogger(logger *zap.Logger, level gorm_logger.LogLevel, slowThreshold time.Duration) *gormLogger {
+	return &gormLogger{
+		logger:        logger,
+		level:         level,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (l *gormLogger) LogMode(level gorm_logger.LogLevel) gorm_logger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gorm_logger.Info {
+		logger.Ctx(ctx, l.logger).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gorm_logger.Warn {
+		logger.Ctx(ctx, l.logger).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gorm_logger.Error {
+		logger.Ctx(ctx, l.logger).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gorm_logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	fields := func() []zap.Field {
+		sql, rows := fc()
+		return []zap.Field{
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Duration("elapsed", elapsed),
+			// the zap caller is always this file
+			zap.String("source", queryCaller()),
+		}
+	}
+
+	switch {
+	case err != nil && l.level >= gorm_logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		logger.Ctx(ctx, l.logger).Error("Query failed", append(fields(), zap.Error(err))...)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gorm_logger.Warn:
+		logger.Ctx(ctx, l.logger).Warn("Slow query", append(fields(), zap.Duration("threshold", l.slowThreshold))...)
+	case l.level >= gorm_logger.Info:
+		logger.Ctx(ctx, l.logger).Debug("Query", fields()...)
+	}
+}
+
+// queryCaller returns file:line of the code that ran the statement, the first
+// frame outside of gorm and this file.
+func queryCaller() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "gorm.io/") && !strings.HasSuffix(frame.File, "pgconn/logger.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package pgconn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Migration is a single versioned schema change read from a pair of
+// "<version>_<name>.up.sql" / "<version>_<name>.down.sql" files.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// row of the migrations table
+type schemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+const (
+	migrationsTable = "schema_migrations"
+
+	// arbitrary key shared by every replica, see pg_advisory_lock
+	migrationLockKey int64 = 0x66756e6365647570
+)
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrMigrationLocked  = errors.New("timed out waiting for migration lock")
+
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_\-]+)\.(up|down)\.sql$`)
+)
+
+func (schemaMigration) TableName() string {
+	return migrationsTable
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Reads migrations from the root of fsys, ordered by version.
+// Every version needs an up file, down files are optional.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(body)
+			sum := sha256.Sum256(body)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Returns migrations without the given versions, e.g. the migrations of a
+// tenant schema without the shared ones.
+func ExcludeMigrations(migrations []Migration, versions []int64) []Migration {
+	excluded := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		excluded[version] = true
+	}
+
+	kept := make([]Migration, 0, len(migrations))
+	for _, migration := range migrations {
+		if !excluded[migration.Version] {
+			kept = append(kept, migration)
+		}
+	}
+	return kept
+}
+
+// Applies pending migrations from fsys at startup when database.migrate is set.
+// In dry run mode the pending SQL is only logged.
+func (m *Module) ApplyMigrations(fsys fs.FS) error {
+	if !m.config.Migrate {
+		m.logger.Info("Skipping versioned migrations.")
+		return nil
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.MigrationTimeout)
+	defer cancel()
+
+	return m.MigrateUp(ctx, migrations, m.config.MigrationDryRun)
+}
+
+// Applies all pending migrations in order, each in its own transaction.
+// Fails without applying anything if an applied migration has been modified.
+func (m *Module) MigrateUp(ctx context.Context, migrations []Migration, dryRun bool) error {
+	return m.withMigrationLock(ctx, "", func(conn *gorm.DB) error {
+		return m.migrateUp(conn, migrations, dryRun)
+	})
+}
+
+// Creates the postgres schema of a tenant, see database.tenancy: schema, and
+// applies all pending migrations to it. The schema tracks its migrations in a
+// schema_migrations table of its own. Shared tables such as tenants stay in
+// public, which follows the schema on the search path like it does for
+// requests, so leave their migrations out, see ExcludeMigrations.
+// e.g. m.MigrateSchema(ctx, "tenant_acme", ExcludeMigrations(all, migrations.Shared))
+func (m *Module) MigrateSchema(ctx context.Context, schemaName string, migrations []Migration) error {
+	if schemaName == "" {
+		return errors.New("MigrateSchema needs a schema")
+	}
+	return m.withMigrationLock(ctx, schemaName, func(conn *gorm.DB) error {
+		return m.migrateUp(conn, migrations, false)
+	})
+}
+
+// Rolls back the latest `steps` applied migrations, newest first.
+func (m *Module) MigrateDown(ctx context.Context, migrations []Migration, steps int, dryRun bool) error {
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	return m.withMigrationLock(ctx, "", func(conn *gorm.DB) error {
+		exists, err := hasMigrationsTable(conn)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			m.logger.Info("No migrations to roll back.")
+			return nil
+		}
+
+		var rows []schemaMigration
+		if err := conn.Order("version DESC").Limit(steps).Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			migration, ok := byVersion[row.Version]
+			if !ok {
+				return fmt.Errorf("applied migration %d_%s is missing from the migration files", row.Version, row.Name)
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+
+			if dryRun {
+				m.logger.Info(fmt.Sprintf("[dry run] rollback migration %d_%s\n%s", migration.Version, migration.Name, migration.Down))
+				continue
+			}
+
+			m.logger.Info("Rolling back migration.", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Lists every known migration and whether it has been applied. Read only,
+// without a migrations table every migration is pending.
+func (m *Module) MigrationStatus(ctx context.Context, migrations []Migration) ([]MigrationStatus, error) {
+	applied, err := m.appliedMigrations(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// migrateUp applies the pending migrations on conn, see MigrateUp.
+func (m *Module) migrateUp(conn *gorm.DB, migrations []Migration, dryRun bool) error {
+	applied, err := m.appliedMigrations(conn)
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	if len(pending) == 0 {
+		m.logger.Info("Database schema is up to date.")
+		return nil
+	}
+
+	if dryRun {
+		for _, migration := range pending {
+			m.logger.Info(fmt.Sprintf("[dry run] pending migration %d_%s\n%s", migration.Version, migration.Name, migration.Up))
+		}
+		return nil
+	}
+
+	// only created once there is something to record, a dry run leaves the
+	// database untouched
+	if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", migrationsTable, err)
+	}
+
+	for _, migration := range pending {
+		m.logger.Info("Applying migration.", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	m.logger.Info("Migrations applied.", zap.Int("count", len(pending)))
+	return nil
+}
+
+// withMigrationLock runs fn on a single connection holding a postgres advisory
+// lock so that replicas starting at the same time migrate one after another.
+// With a schemaName the schema is created and put on the search path of the
+// connection ahead of public until fn returns.
+func (m *Module) withMigrationLock(ctx context.Context, schemaName string, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := m.acquireMigrationLock(ctx, conn); err != nil {
+			return err
+		}
+		defer func() {
+			// the lock is released with the session anyway, but the connection
+			// goes back to the pool so unlock explicitly
+			err := conn.Session(&gorm.Session{Context: context.Background()}).
+				Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).
+				Error
+			if err != nil {
+				m.logger.Error("Error releasing migration lock", zap.Error(err))
+			}
+		}()
+
+		if schemaName != "" {
+			if err := conn.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdentifier(schemaName)).Error; err != nil {
+				return fmt.Errorf("failed to create schema %s: %w", schemaName, err)
+			}
+			if err := conn.Exec("SET search_path TO " + quoteIdentifier(schemaName) + ", public").Error; err != nil {
+				return fmt.Errorf("failed to set search path to %s: %w", schemaName, err)
+			}
+			defer func() {
+				err := conn.Session(&gorm.Session{Context: context.Background()}).Exec("RESET search_path").Error
+				if err != nil {
+					m.logger.Error("Error resetting search path", zap.Error(err))
+				}
+			}()
+		}
+
+		return fn(conn)
+	})
+}
+
+func (m *Module) acquireMigrationLock(ctx context.Context, conn *gorm.DB) error {
+	for {
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", migrationLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+
+		m.logger.Info("Waiting for migration lock held by another instance.")
+		select {
+		case <-ctx.Done():
+			return ErrMigrationLocked
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// appliedMigrations returns the applied migrations by version, none when the
+// migrations table does not exist yet.
+func (m *Module) appliedMigrations(conn *gorm.DB) (map[int64]schemaMigration, error) {
+	exists, err := hasMigrationsTable(conn)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]schemaMigration{}, nil
+	}
+
+	var rows []schemaMigration
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// hasMigrationsTable reports whether the migrations table exists in the
+// current schema of conn, a tenant schema does not use the one of public.
+func hasMigrationsTable(conn *gorm.DB) (bool, error) {
+	var exists bool
+	err := conn.Raw("SELECT to_regclass(format('%I.%I', current_schema(), ?::text)) IS NOT NULL", migrationsTable).
+		Scan(&exists).
+		Error
+	return exists, err
+}
+
+func verifyChecksums(migrations []Migration, applied map[int64]schemaMigration) error {
+	for _, migration := range migrations {
+		row, ok := applied[migration.Version]
+		if !ok {
+			continue
+		}
+		if row.Checksum != migration.Checksum {
+			return fmt.Errorf("%w: %d_%s was changed after it was applied", ErrChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package pgconn
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"funcedup/internal/schema/migrations"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testEmptySchema points m at a schema of its own, so the migrations table of
+// the test database does not interfere.
+func testEmptySchema(t *testing.T, m *Module) {
+	t.Helper()
+
+	name := "migration_check_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := m.db.Exec("CREATE SCHEMA " + quoteIdentifier(name)).Error; err != nil {
+		t.Fatal(err)
+	}
+	owner := m.db
+	t.Cleanup(func() {
+		owner.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdentifier(name)))
+	})
+
+	db, err := gorm.Open(postgres.Open(m.getConnectionStringFromConfig()+" search_path="+name), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.db = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+func TestMigrationStatusWithoutMigrationsTable(t *testing.T) {
+	m := testModule(t, TenancyNone)
+	testEmptySchema(t, m)
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"0001_first.up.sql":  {Data: []byte("SELECT 1")},
+		"0002_second.up.sql": {Data: []byte("SELECT 2")},
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.MigrationStatus(ctx, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses, want 2", len(statuses))
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("migration %d_%s is reported as applied", status.Version, status.Name)
+		}
+	}
+
+	// status is read only
+	exists, err := hasMigrationsTable(m.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("MigrationStatus created the %s table", migrationsTable)
+	}
+
+	// migrations are not managed, nothing to wait for
+	if err := m.MigrationCheck(fsys)(ctx); err != nil {
+		t.Errorf("MigrationCheck without database.migrate returned %v", err)
+	}
+
+	m.config.Migrate = true
+	if err := m.MigrationCheck(fsys)(ctx); err == nil {
+		t.Error("MigrationCheck with database.migrate passed while migrations are pending")
+	}
+
+	// a dry run leaves the database untouched
+	if err := m.MigrateUp(ctx, migrations, true); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := hasMigrationsTable(m.db); err != nil || exists {
+		t.Errorf("dry run created the %s table: %v", migrationsTable, err)
+	}
+	if err := m.MigrateDown(ctx, migrations, 1, false); err != nil {
+		t.Errorf("MigrateDown without migrations table returned %v", err)
+	}
+
+	if err := m.MigrateUp(ctx, migrations, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MigrationCheck(fsys)(ctx); err != nil {
+		t.Errorf("MigrationCheck after migrate up returned %v", err)
+	}
+}
+
+// baselineSchema is what GORM AutoMigrate created before versioned migrations:
+// uuid foreign keys stored as text, no unique indexes and neither note bodies
+// nor reply parents.
+const baselineSchema = `
+CREATE TABLE users (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, username text, email text, password_hash text, points bigint);
+CREATE TABLE contents (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, title text, body text, owner_id text);
+CREATE TABLE tags (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, name text);
+CREATE TABLE content_tags (
+    content_id uuid REFERENCES contents (id), tag_id uuid REFERENCES tags (id),
+    id uuid DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, relationship text,
+    PRIMARY KEY (content_id, tag_id)
+);
+CREATE TABLE discussions (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, owner_id text, content_id text);
+CREATE TABLE discusion_replies (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, owner_id text, discussion_id text, content_id text);
+CREATE TABLE notes (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, owner_id text, content_id text);
+CREATE TABLE note_replies (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), created_at timestamptz, updated_at timestamptz, owner_id text, note_id text, content_id text);
+`
+
+func TestMigrateUpAdoptsAutoMigrateSchema(t *testing.T) {
+	m := testModule(t, TenancyNone)
+	testEmptySchema(t, m)
+	ctx := context.Background()
+
+	if err := m.db.Exec(baselineSchema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	owner, content, older, newer := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	seed := []struct {
+		sql  string
+		args []interface{}
+	}{
+		{"INSERT INTO users (id, username, email) VALUES (?, 'ada', 'ada@example.com')", []interface{}{owner}},
+		{"INSERT INTO contents (id, title, owner_id) VALUES (?, 'title', ?)", []interface{}{content, owner.String()}},
+		{"INSERT INTO tags (id, created_at, name) VALUES (?, now() - interval '1 day', 'go'), (?, now(), 'go')", []interface{}{older, newer}},
+		{"INSERT INTO content_tags (content_id, tag_id) VALUES (?, ?), (?, ?)", []interface{}{content, older, content, newer}},
+		{"INSERT INTO notes (owner_id, content_id) VALUES (?, ?)", []interface{}{owner.String(), content.String()}},
+	}
+	for _, row := range seed {
+		if err := m.db.Exec(row.sql, row.args...).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MigrateUp(ctx, all, false); err != nil {
+		t.Fatal(err)
+	}
+
+	columns := map[string]string{
+		"notes":             "visibility",
+		"discusion_replies": "parent_id",
+		"users":             "tenant_id",
+	}
+	for table, column := range columns {
+		var found int64
+		err := m.db.Raw(
+			"SELECT count(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?",
+			table, column,
+		).Scan(&found).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != 1 {
+			t.Errorf("%s.%s is missing", table, column)
+		}
+	}
+
+	var visibility string
+	if err := m.db.Raw("SELECT visibility FROM notes").Scan(&visibility).Error; err != nil {
+		t.Fatal(err)
+	}
+	if visibility != "private" {
+		t.Errorf("adopted note has visibility %q, want private", visibility)
+	}
+
+	// duplicate tags are merged into the oldest one
+	var tags []uuid.UUID
+	if err := m.db.Raw("SELECT tag_id FROM content_tags WHERE content_id = ?", content).Scan(&tags).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != older {
+		t.Errorf("content is tagged with %v, want [%s]", tags, older)
+	}
+
+	var foreignKeys int64
+	err = m.db.Raw(
+		"SELECT count(*) FROM pg_constraint WHERE contype = 'f' AND conrelid = 'users'::regclass AND confrelid = 'tenants'::regclass",
+	).Scan(&foreignKeys).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if foreignKeys != 1 {
+		t.Errorf("users.tenant_id has %d foreign keys to tenants, want 1", foreignKeys)
+	}
+
+	statuses, err := m.MigrationStatus(ctx, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %d_%s is pending", status.Version, status.Name)
+		}
+	}
+}
+
+func TestMigrateSchemaSharesPublicTables(t *testing.T) {
+	m := testModule(t, TenancySchema)
+	ctx := context.Background()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	schemaName, shared := "migration_check_"+suffix, "migration_check_shared_"+suffix
+	if err := m.db.Exec(fmt.Sprintf("CREATE TABLE public.%s (id int PRIMARY KEY)", shared)).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.db.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdentifier(schemaName)))
+		m.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS public.%s", shared))
+	})
+
+	fsys := fstest.MapFS{
+		"0001_shared.up.sql": {Data: []byte(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id int PRIMARY KEY)", shared))},
+		"0002_items.up.sql":  {Data: []byte(fmt.Sprintf("CREATE TABLE items (id serial PRIMARY KEY, shared_id int REFERENCES %s (id))", shared))},
+	}
+	all, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenantMigrations := ExcludeMigrations(all, []int64{1})
+	if len(tenantMigrations) != 1 || tenantMigrations[0].Version != 2 {
+		t.Fatalf("ExcludeMigrations kept %v, want version 2 only", tenantMigrations)
+	}
+	if err := m.MigrateSchema(ctx, schemaName, tenantMigrations); err != nil {
+		t.Fatal(err)
+	}
+
+	var local bool
+	if err := m.db.Raw("SELECT to_regclass(?) IS NOT NULL", schemaName+"."+shared).Scan(&local).Error; err != nil {
+		t.Fatal(err)
+	}
+	if local {
+		t.Errorf("the tenant schema got a %s table of its own", shared)
+	}
+
+	var references string
+	err = m.db.Raw(
+		"SELECT confrelid::regclass::text FROM pg_constraint WHERE contype = 'f' AND conrelid = ?::regclass",
+		schemaName+".items",
+	).Scan(&references).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if references != "public."+shared && references != shared {
+		t.Errorf("items references %q, want the shared table of public", references)
+	}
+
+	// the schema tracks its migrations itself, whatever public has applied
+	var applied []int64
+	err = m.db.Raw(fmt.Sprintf("SELECT version FROM %s.%s ORDER BY version", quoteIdentifier(schemaName), migrationsTable)).
+		Scan(&applied).
+		Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(applied) != "[2]" {
+		t.Errorf("tenant schema applied %v, want [2]", applied)
+	}
+}
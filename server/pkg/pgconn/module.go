@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"funcedup/pkg/config"
 	"funcedup/pkg/util"
 
 	"github.com/spf13/viper"
@@ -30,13 +32,23 @@ type Params struct {
 }
 
 type Config struct {
-	DBName   string
-	Host     string
+	DBName   string `mapstructure:"dbname" validate:"required"`
+	Host     string `mapstructure:"host" validate:"required"`
 	LogLevel string
-	Password string
-	Port     int
-	SSLMode  string
-	User     string
+	Password string `mapstructure:"password" config:"secret"`
+	Port     int    `mapstructure:"port" validate:"min=1,max=65535"`
+	SSLMode  string `mapstructure:"sslmode" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	User     string `mapstructure:"user" validate:"required"`
+
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold" validate:"gte=0"`
+
+	AutoMigrate      bool          `mapstructure:"auto_migrate"`
+	Migrate          bool          `mapstructure:"migrate"`
+	MigrationDryRun  bool          `mapstructure:"migration_dry_run"`
+	MigrationTimeout time.Duration `mapstructure:"migration_timeout" validate:"gt=0"`
+
+	Tenancy          string `mapstructure:"tenancy" validate:"oneofci=none row schema"`
+	RowLevelSecurity bool   `mapstructure:"row_level_security"`
 }
 
 const (
@@ -47,6 +59,16 @@ const (
 	DefaultPassword = "postgres"
 	DefaultSSLMode  = "allow"
 	DefaultLogLevel = "info"
+
+	DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+	DefaultAutoMigrate      = false
+	DefaultMigrate          = false
+	DefaultMigrationDryRun  = false
+	DefaultMigrationTimeout = 5 * time.Minute
+
+	DefaultTenancy          = TenancyNone
+	DefaultRowLevelSecurity = false
 )
 
 //! Module ---------------------------------------------------------------
@@ -55,14 +77,18 @@ const (
 func InjectModule(scope string) fx.Option {
 	return fx.Module(
 		scope,
-		fx.Provide(func(p Params) *Module {
+		fx.Provide(func(p Params) (*Module, error) {
 
 			m := &Module{scope: scope}
 			m.config = m.setupConfig(scope)
 			m.logger = m.setupLogger(scope, p)
+			// connecting with a bad config would only report the first problem
+			if err := config.Check(); err != nil {
+				return nil, err
+			}
 			m.db = m.setUpDB()
 
-			return m
+			return m, nil
 		}),
 		fx.Invoke(func(m *Module, p Params) {
 			p.Lifecycle.Append(
@@ -90,24 +116,32 @@ func NewPGConn(scope string, logger *zap.Logger) *Module {
 //! INTERNAL ---------------------------------------------------------------
 
 func (m *Module) setupConfig(scope string) *Config {
-	//set default values
-	viper.SetDefault(util.GetConfigPath(scope, "host"), DefaultHost)
-	viper.SetDefault(util.GetConfigPath(scope, "port"), DefaultPort)
-	viper.SetDefault(util.GetConfigPath(scope, "dbname"), DefaultDbName)
-	viper.SetDefault(util.GetConfigPath(scope, "user"), DefaultUser)
-	viper.SetDefault(util.GetConfigPath(scope, "password"), DefaultPassword)
-	viper.SetDefault(util.GetConfigPath(scope, "sslmode"), DefaultSSLMode)
-	viper.SetDefault(util.GetConfigPath("global", "log_level"), DefaultLogLevel)
-
-	return &Config{
-		Host:     viper.GetString(util.GetConfigPath(scope, "host")),
-		Port:     viper.GetInt(util.GetConfigPath(scope, "port")),
-		DBName:   viper.GetString(util.GetConfigPath(scope, "dbname")),
-		User:     viper.GetString(util.GetConfigPath(scope, "user")),
-		Password: viper.GetString(util.GetConfigPath(scope, "password")),
-		SSLMode:  viper.GetString(util.GetConfigPath(scope, "sslmode")),
-		LogLevel: viper.GetString(util.GetConfigPath("global", "log_level")),
+	config.SetDefault(util.GetConfigPath("global", "log_level"), DefaultLogLevel)
+
+	c := &Config{
+		Host:     DefaultHost,
+		Port:     DefaultPort,
+		DBName:   DefaultDbName,
+		User:     DefaultUser,
+		Password: DefaultPassword,
+		SSLMode:  DefaultSSLMode,
+
+		SlowQueryThreshold: DefaultSlowQueryThreshold,
+
+		AutoMigrate:      DefaultAutoMigrate,
+		Migrate:          DefaultMigrate,
+		MigrationDryRun:  DefaultMigrationDryRun,
+		MigrationTimeout: DefaultMigrationTimeout,
+
+		Tenancy:          DefaultTenancy,
+		RowLevelSecurity: DefaultRowLevelSecurity,
 	}
+	config.Load(scope, c)
+
+	c.LogLevel = viper.GetString(util.GetConfigPath("global", "log_level"))
+	c.Tenancy = strings.ToLower(c.Tenancy)
+
+	return c
 }
 
 func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
@@ -119,12 +153,20 @@ func (m *Module) setUpDB() *gorm.DB {
 	dsn := m.getConnectionStringFromConfig()
 	loglevel := m.getLogLevelFromConfig()
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: gorm_logger.Default.LogMode(loglevel),
+		Logger: newGormLogger(m.logger, loglevel, m.config.SlowQueryThreshold),
+		// translate driver errors into gorm errors, e.g. gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		m.logger.Fatal("Error connecting to database", zap.Error(err))
 	}
 
+	if m.config.Tenancy == TenancyRow {
+		if err := registerTenantCallbacks(db); err != nil {
+			m.logger.Fatal("Error registering tenant callbacks", zap.Error(err))
+		}
+	}
+
 	return db
 }
 
@@ -148,9 +190,28 @@ func (m *Module) getLogLevelFromConfig() gorm_logger.LogLevel {
 	}
 }
 
-func (m *Module) onStart(context.Context) error {
+func (m *Module) onStart(ctx context.Context) error {
 	m.logger.Info("Starting database connection.")
 
+	switch m.config.Tenancy {
+	case TenancyNone, TenancyRow, TenancySchema:
+	default:
+		return fmt.Errorf("invalid database.tenancy %q, expected %s, %s or %s", m.config.Tenancy, TenancyNone, TenancyRow, TenancySchema)
+	}
+
+	if m.config.RowLevelSecurity {
+		if m.config.Tenancy != TenancyRow {
+			return ErrRLSRequiresRowTenancy
+		}
+		bypass, err := m.RoleBypassesRLS(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check database role: %w", err)
+		}
+		if bypass {
+			m.logger.Warn("Connected as a superuser or BYPASSRLS role, row level security policies are not enforced.", zap.String("user", m.config.User))
+		}
+	}
+
 	if viper.GetString("global.log_level") == "DEBUG" || viper.GetString("global.log_level") == "debug" {
 		m.logConfigurations()
 	}
@@ -181,17 +242,25 @@ func (m *Module) logConfigurations() {
 	m.logger.Debug("Port", zap.Int("port", m.config.Port))
 	m.logger.Debug("DBName", zap.String("dbname", m.config.DBName))
 	m.logger.Debug("User", zap.String("user", m.config.User))
+	m.logger.Debug("Password", zap.String("password", config.Redact(m.config.Password)))
 	m.logger.Debug("SSLMode", zap.String("sslmode", m.config.SSLMode))
 	m.logger.Debug("LogLevel", zap.String("log_level", m.config.LogLevel))
+	m.logger.Debug("SlowQueryThreshold", zap.Duration("slow_query_threshold", m.config.SlowQueryThreshold))
+	m.logger.Debug("AutoMigrate", zap.Bool("auto_migrate", m.config.AutoMigrate))
+	m.logger.Debug("Migrate", zap.Bool("migrate", m.config.Migrate))
+	m.logger.Debug("MigrationDryRun", zap.Bool("migration_dry_run", m.config.MigrationDryRun))
+	m.logger.Debug("MigrationTimeout", zap.Duration("migration_timeout", m.config.MigrationTimeout))
+	m.logger.Debug("Tenancy", zap.String("tenancy", m.config.Tenancy))
+	m.logger.Debug("RowLevelSecurity", zap.Bool("row_level_security", m.config.RowLevelSecurity))
 }
 
 //! EXTERNAL ---------------------------------------------------------------
 
-// Applies the passed in schema.
-// If autoMigrate is true, it will automatically migrate the schema at startup.
-// If autoMigrate is false, it will skip the migration.
-func (m *Module) ApplySchema(autoMigrate bool, schema ...interface{}) {
-	if !autoMigrate {
+// Applies the passed in schema with GORM AutoMigrate.
+// Only runs when database.auto_migrate is true, intended for local development.
+// Use versioned migrations, see ApplyMigrations, everywhere else.
+func (m *Module) ApplySchema(schema ...interface{}) {
+	if !m.config.AutoMigrate {
 		m.logger.Info("Skipping auto migration.")
 		return
 	}
@@ -204,7 +273,20 @@ func (m *Module) ApplySchema(autoMigrate bool, schema ...interface{}) {
 	m.logger.Info("Migration completed.")
 }
 
-// Returns the GORM DB instance
+// Returns the GORM DB instance.
+// With database.tenancy: row, statements on tenant scoped models need a
+// tenant on their context, prefer Scoped or AllTenants.
 func (m *Module) GetDB() *gorm.DB {
 	return m.db
 }
+
+// Returns the name of the configured database.
+func (m *Module) DBName() string {
+	return m.config.DBName
+}
+
+// Closes the connection pool. Only needed when the module is used without
+// starting the fx lifecycle, e.g. by one-off commands.
+func (m *Module) Close() error {
+	return m.onStop(context.Background())
+}
@@ -0,0 +1,163 @@
+package pgconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Settings the row level security policies read, set per transaction by
+// Transaction from the request context.
+const (
+	settingCurrentTenant = "app.current_tenant"
+	settingAllTenants    = "app.all_tenants"
+
+	rlsPolicyName = "tenant_isolation"
+)
+
+// RLSTable reports the row level security state of a table.
+type RLSTable struct {
+	Table        string
+	TenantScoped bool // has a tenant_id column
+	Enabled      bool
+	Forced       bool
+	Policies     int
+}
+
+// Returns true when a tenant scoped table is not protected by a policy.
+func (t RLSTable) Missing() bool {
+	return t.TenantScoped && (!t.Enabled || !t.Forced || t.Policies == 0)
+}
+
+var ErrRLSRequiresRowTenancy = errors.New("row level security requires database.tenancy: row")
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns true when database.row_level_security is set.
+func (m *Module) RowLevelSecurity() bool {
+	return m.config.RowLevelSecurity
+}
+
+// Returns the statements that enable row level security on every tenant
+// scoped model. Rows are visible when their tenant_id matches
+// app.current_tenant, or to jobs using AllTenants. Without a tenant nothing
+// is visible, so queries outside of Transaction fail closed.
+func (m *Module) RLSStatements(models ...interface{}) ([]string, error) {
+	var statements []string
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: m.db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		if stmt.Schema.LookUpField(tenantColumn) == nil {
+			continue
+		}
+
+		table := quoteIdentifier(stmt.Schema.Table)
+		condition := fmt.Sprintf(
+			"current_setting('%s', true) = 'on' OR %s = NULLIF(current_setting('%s', true), '')::uuid",
+			settingAllTenants, tenantColumn, settingCurrentTenant,
+		)
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+			// also applies the policy to the table owner
+			fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", table),
+			fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", rlsPolicyName, table),
+			fmt.Sprintf("CREATE POLICY %s ON %s USING (%s) WITH CHECK (%s)", rlsPolicyName, table, condition, condition),
+		)
+	}
+	return statements, nil
+}
+
+// Creates or replaces the row level security policies of models in a single
+// transaction. In dry run mode the statements are only logged.
+func (m *Module) ApplyRLS(ctx context.Context, dryRun bool, models ...interface{}) error {
+	if m.config.Tenancy != TenancyRow {
+		return ErrRLSRequiresRowTenancy
+	}
+
+	statements, err := m.RLSStatements(models...)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		for _, statement := range statements {
+			m.logger.Info("[dry run] " + statement)
+		}
+		return nil
+	}
+
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("%s: %w", statement, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("Row level security policies applied.", zap.Int("statements", len(statements)))
+	return nil
+}
+
+// Lists the tables of the current schema with their row level security state.
+func (m *Module) RLSStatus(ctx context.Context) ([]RLSTable, error) {
+	var tables []RLSTable
+	err := m.db.WithContext(ctx).Raw(`
+		SELECT c.relname AS "table",
+		       EXISTS (
+		           SELECT 1 FROM pg_attribute a
+		           WHERE a.attrelid = c.oid AND a.attname = ? AND NOT a.attisdropped
+		       ) AS tenant_scoped,
+		       c.relrowsecurity AS enabled,
+		       c.relforcerowsecurity AS forced,
+		       (SELECT count(*) FROM pg_policy p WHERE p.polrelid = c.oid) AS policies
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = current_schema()
+		ORDER BY c.relname`, tenantColumn).
+		Scan(&tables).
+		Error
+	return tables, err
+}
+
+// Returns true when the connected role is a superuser or has BYPASSRLS, in
+// which case postgres ignores every policy.
+func (m *Module) RoleBypassesRLS(ctx context.Context) (bool, error) {
+	var bypass bool
+	err := m.db.WithContext(ctx).
+		Raw("SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").
+		Scan(&bypass).
+		Error
+	return bypass, err
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// setRLSContext copies the tenant of the transaction context into the
+// transaction local settings read by the policies.
+func (m *Module) setRLSContext(tx *gorm.DB) error {
+	ctx := tx.Statement.Context
+	var settings [][2]string
+
+	if all, _ := ctx.Value(allTenantsContextKey{}).(bool); all {
+		settings = append(settings, [2]string{settingAllTenants, "on"})
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		settings = append(settings, [2]string{settingCurrentTenant, tenant.ID.String()})
+	}
+
+	for _, setting := range settings {
+		if err := tx.Exec("SELECT set_config(?, ?, true)", setting[0], setting[1]).Error; err != nil {
+			return fmt.Errorf("failed to set %s: %w", setting[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package pgconn
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// testAppModule connects like the application, as SERVER_TEST_DATABASE_APP_USER,
+// a role that is neither superuser nor BYPASSRLS, with row level security on.
+// owner is the module of the role that owns the tables.
+func testAppModule(t *testing.T, owner *Module) *Module {
+	t.Helper()
+
+	user := os.Getenv("SERVER_TEST_DATABASE_APP_USER")
+	if user == "" {
+		t.Skip("SERVER_TEST_DATABASE_APP_USER is not set")
+	}
+
+	config := *owner.config
+	config.User = user
+	config.Password = os.Getenv("SERVER_TEST_DATABASE_APP_PASSWORD")
+	config.RowLevelSecurity = true
+
+	m := &Module{scope: owner.scope, logger: owner.logger, config: &config}
+	m.db = m.setUpDB()
+	t.Cleanup(func() { m.Close() })
+
+	bypass, err := m.RoleBypassesRLS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bypass {
+		t.Fatalf("%s is a superuser or BYPASSRLS role, policies would not apply", user)
+	}
+	return m
+}
+
+func TestRowLevelSecurityIsolatesTenants(t *testing.T) {
+	owner := testModule(t, TenancyRow)
+	app := testAppModule(t, owner)
+	acme, globex := testTenant("acme"), testTenant("globex")
+	ctx := context.Background()
+
+	table := isolationRow{}.TableName()
+	if err := owner.db.AutoMigrate(&isolationRow{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		owner.db.Migrator().DropTable(&isolationRow{})
+	})
+	if err := owner.ApplyRLS(ctx, false, &isolationRow{}); err != nil {
+		t.Fatal(err)
+	}
+	grants := []string{
+		fmt.Sprintf("GRANT SELECT, INSERT, UPDATE, DELETE ON %s TO %s", table, quoteIdentifier(app.config.User)),
+		fmt.Sprintf("GRANT USAGE ON SEQUENCE %s_id_seq TO %s", table, quoteIdentifier(app.config.User)),
+	}
+	for _, grant := range grants {
+		if err := owner.db.Exec(grant).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// written through the tenant callbacks, the policies check the tenant_id
+	insert(t, app, acme, "acme")
+	insert(t, app, globex, "globex")
+
+	// raw SQL skips the tenant filter of GORM, only the policies apply
+	visible := func(ctx context.Context) []string {
+		t.Helper()
+
+		var found []string
+		err := app.Transaction(ctx, func(tx *gorm.DB) error {
+			return tx.Raw("SELECT body FROM " + table + " ORDER BY body").Scan(&found).Error
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	expectBodies(t, acme, visible(WithTenant(ctx, acme)), "acme")
+	expectBodies(t, globex, visible(WithTenant(ctx, globex)), "globex")
+	expectBodies(t, Tenant{Slug: "(all tenants)"}, visible(AllTenantsContext(ctx)), "acme", "globex")
+	expectBodies(t, Tenant{Slug: "(no tenant)"}, visible(ctx))
+
+	err := app.Transaction(WithTenant(ctx, acme), func(tx *gorm.DB) error {
+		return tx.Exec("INSERT INTO "+table+" (body, tenant_id) VALUES (?, ?)", "intruder", globex.ID).Error
+	})
+	if err == nil {
+		t.Error("acme wrote a row of globex")
+	}
+}
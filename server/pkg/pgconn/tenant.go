@@ -0,0 +1,249 @@
+package pgconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How tenants are isolated, see database.tenancy.
+const (
+	TenancyNone   = "none"   // single tenant deployment
+	TenancyRow    = "row"    // shared tables, rows carry a tenant_id
+	TenancySchema = "schema" // one postgres schema per tenant
+)
+
+// Tenant a request or job runs for.
+type Tenant struct {
+	ID     uuid.UUID
+	Slug   string
+	Schema string // postgres schema of the tenant, only used with TenancySchema
+}
+
+// Column that marks a model as tenant scoped, see schema.TenantBaseModel.
+const tenantColumn = "tenant_id"
+
+var (
+	ErrTenantRequired            = errors.New("query on tenant scoped table without a tenant, use pgconn.WithTenant or AllTenants")
+	ErrTenantMismatch            = errors.New("row belongs to a different tenant")
+	ErrTenantTransactionRequired = errors.New("schema tenancy needs a transaction to set the search path, use pgconn.WithTransaction")
+)
+
+type tenantContextKey struct{}
+type allTenantsContextKey struct{}
+type transactionContextKey struct{}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns a copy of ctx carrying the tenant.
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// Returns the tenant stored on ctx by WithTenant.
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(Tenant)
+	return tenant, ok
+}
+
+// Returns the configured tenancy mode.
+func (m *Module) Tenancy() string {
+	return m.config.Tenancy
+}
+
+// Returns the DB bound to ctx. With row tenancy, queries, updates and deletes
+// on tenant scoped models are filtered by the tenant on ctx and inserts are
+// assigned to it. Without a tenant they fail with ErrTenantRequired.
+// With schema tenancy the search path is only set per transaction, statements
+// for a tenant outside of WithTransaction fail with
+// ErrTenantTransactionRequired instead of reaching the public schema.
+// Inside WithTransaction the transaction of ctx is returned.
+// e.g. d.params.DB.Scoped(c.Request().Context()).Find(&contents)
+func (m *Module) Scoped(ctx context.Context) *gorm.DB {
+	db := m.session(ctx)
+	if m.config.Tenancy != TenancySchema || inTransaction(ctx) {
+		return db
+	}
+	if all, _ := ctx.Value(allTenantsContextKey{}).(bool); all {
+		return db
+	}
+	if tenant, ok := TenantFromContext(ctx); ok {
+		db.AddError(fmt.Errorf("%w: tenant %s", ErrTenantTransactionRequired, tenant.Slug))
+	}
+	return db
+}
+
+// Returns a DB that bypasses tenant filtering, for jobs that work across
+// tenants such as seeding or maintenance. Inserted rows keep the tenant they
+// were given, nil if none.
+// Row level security policies only let it through inside
+// Transaction(AllTenantsContext(ctx), ...), or for roles with BYPASSRLS.
+// ! Never use it for request handling.
+func (m *Module) AllTenants(ctx context.Context) *gorm.DB {
+	return m.Scoped(AllTenantsContext(ctx))
+}
+
+// Returns a copy of ctx that bypasses tenant filtering, see AllTenants.
+func AllTenantsContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsContextKey{}, true)
+}
+
+// Runs fn in a transaction scoped to the tenant on ctx, if any.
+// With schema tenancy the search path is set to the tenant schema for the
+// duration of the transaction, so unqualified table names resolve to it.
+// With row level security the tenant is set for the policies.
+// Nested calls run in a savepoint of the outer transaction.
+func (m *Module) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return m.session(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := m.scopeTransaction(tx); err != nil {
+			return err
+		}
+		if m.config.RowLevelSecurity {
+			if err := m.setRLSContext(tx); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// Runs fn with a ctx carrying a transaction opened by Transaction, every
+// Scoped(ctx) call within fn uses it. The transaction commits when fn
+// returns nil and rolls back otherwise.
+func (m *Module) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.Transaction(ctx, func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, transactionContextKey{}, tx))
+	})
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// session returns the transaction of ctx, see WithTransaction, or a new
+// session of the pool.
+func (m *Module) session(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(transactionContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return m.db.WithContext(ctx)
+}
+
+func inTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(transactionContextKey{}).(*gorm.DB)
+	return ok
+}
+
+func (m *Module) scopeTransaction(tx *gorm.DB) error {
+	tenant, ok := TenantFromContext(tx.Statement.Context)
+	if !ok || m.config.Tenancy != TenancySchema {
+		return nil
+	}
+	if tenant.Schema == "" {
+		return fmt.Errorf("tenant %s has no schema", tenant.Slug)
+	}
+
+	// SET does not take bind parameters, set_config does and is transaction
+	// local when its last argument is true
+	err := tx.Exec("SELECT set_config('search_path', ?, true)", quoteIdentifier(tenant.Schema)+", public").Error
+	if err != nil {
+		return fmt.Errorf("failed to set search path for tenant %s: %w", tenant.Slug, err)
+	}
+	return nil
+}
+
+// registerTenantCallbacks makes every statement on a tenant scoped model
+// filter by, or assign, the tenant on its context.
+// Raw SQL and tables referenced by name only, e.g. in joins and subqueries,
+// are not rewritten.
+func registerTenantCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", filterByTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:row", filterByTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", filterByTenant); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:delete", filterByTenant); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:create", assignTenant)
+}
+
+// statementTenant returns the tenant of a statement on a tenant scoped model.
+// scoped is false for other models and AllTenants statements.
+func statementTenant(db *gorm.DB) (tenant Tenant, scoped bool) {
+	stmt := db.Statement
+	if stmt.Schema == nil || stmt.Schema.LookUpField(tenantColumn) == nil {
+		return Tenant{}, false
+	}
+	if all, _ := stmt.Context.Value(allTenantsContextKey{}).(bool); all {
+		return Tenant{}, false
+	}
+
+	tenant, ok := TenantFromContext(stmt.Context)
+	if !ok {
+		db.AddError(fmt.Errorf("%w: %s", ErrTenantRequired, stmt.Schema.Table))
+		return Tenant{}, false
+	}
+	return tenant, true
+}
+
+func filterByTenant(db *gorm.DB) {
+	// raw sql is sent as is
+	if db.Statement.SQL.Len() > 0 {
+		return
+	}
+
+	tenant, scoped := statementTenant(db)
+	if !scoped {
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: tenant.ID},
+	}})
+}
+
+func assignTenant(db *gorm.DB) {
+	tenant, scoped := statementTenant(db)
+	if !scoped {
+		return
+	}
+
+	stmt := db.Statement
+	field := stmt.Schema.LookUpField(tenantColumn)
+	assign := func(row reflect.Value) {
+		if value, zero := field.ValueOf(stmt.Context, row); !zero {
+			if id, ok := value.(*uuid.UUID); ok && id != nil && *id != tenant.ID {
+				db.AddError(fmt.Errorf("%w: %s", ErrTenantMismatch, stmt.Schema.Table))
+				return
+			}
+		}
+		id := tenant.ID
+		if err := field.Set(stmt.Context, row, &id); err != nil {
+			db.AddError(err)
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			assign(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		assign(stmt.ReflectValue)
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,189 @@
+package pgconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Tests against postgres only run with SERVER_TEST_DATABASE_HOST set, see
+// scripts/test-rls.sh. The other SERVER_TEST_DATABASE_* variables default to
+// the docker-compose setup.
+func testModule(t *testing.T, tenancy string) *Module {
+	t.Helper()
+
+	host := os.Getenv("SERVER_TEST_DATABASE_HOST")
+	if host == "" {
+		t.Skip("SERVER_TEST_DATABASE_HOST is not set")
+	}
+	env := func(name string, fallback string) string {
+		if value := os.Getenv("SERVER_TEST_DATABASE_" + name); value != "" {
+			return value
+		}
+		return fallback
+	}
+	port, err := strconv.Atoi(env("PORT", strconv.Itoa(DefaultPort)))
+	if err != nil {
+		t.Fatalf("invalid SERVER_TEST_DATABASE_PORT: %v", err)
+	}
+
+	m := &Module{
+		scope:  "database",
+		logger: zap.NewNop(),
+		config: &Config{
+			Host:     host,
+			Port:     port,
+			DBName:   env("DBNAME", DefaultDbName),
+			User:     env("USER", DefaultUser),
+			Password: env("PASSWORD", DefaultPassword),
+			SSLMode:  env("SSLMODE", "disable"),
+			LogLevel: "prod",
+			Tenancy:  tenancy,
+		},
+	}
+	m.db = m.setUpDB()
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// isolationRow is a tenant scoped model of its own, so the tests do not
+// depend on the application schema.
+type isolationRow struct {
+	ID       uint `gorm:"primaryKey"`
+	Body     string
+	TenantID *uuid.UUID `gorm:"type:uuid"`
+}
+
+func (isolationRow) TableName() string {
+	return "tenancy_isolation_check"
+}
+
+func testTenant(slug string) Tenant {
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return Tenant{ID: uuid.New(), Slug: slug, Schema: "tenancy_check_" + slug + "_" + suffix}
+}
+
+// bodies returns the bodies of the rows tenant sees.
+func bodies(t *testing.T, m *Module, tenant Tenant) []string {
+	t.Helper()
+
+	var found []string
+	err := m.WithTransaction(WithTenant(context.Background(), tenant), func(ctx context.Context) error {
+		return m.Scoped(ctx).Model(&isolationRow{}).Order("body").Pluck("body", &found).Error
+	})
+	if err != nil {
+		t.Fatalf("tenant %s: %v", tenant.Slug, err)
+	}
+	return found
+}
+
+func insert(t *testing.T, m *Module, tenant Tenant, body string) {
+	t.Helper()
+
+	err := m.WithTransaction(WithTenant(context.Background(), tenant), func(ctx context.Context) error {
+		return m.Scoped(ctx).Create(&isolationRow{Body: body}).Error
+	})
+	if err != nil {
+		t.Fatalf("tenant %s: %v", tenant.Slug, err)
+	}
+}
+
+func expectBodies(t *testing.T, tenant Tenant, got []string, want ...string) {
+	t.Helper()
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tenant %s sees %v, want %v", tenant.Slug, got, want)
+	}
+}
+
+func TestSchemaTenancyIsolatesTenants(t *testing.T) {
+	m := testModule(t, TenancySchema)
+	acme, globex := testTenant("acme"), testTenant("globex")
+
+	// the table exists in public too, a missing search path would write there
+	// instead of failing
+	table := isolationRow{}.TableName()
+	for _, schema := range []string{acme.Schema, globex.Schema, "public"} {
+		statements := []string{
+			fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdentifier(schema)),
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (id serial PRIMARY KEY, body text, tenant_id uuid)", quoteIdentifier(schema), table),
+		}
+		for _, statement := range statements {
+			if err := m.db.Exec(statement).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	t.Cleanup(func() {
+		m.db.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s, %s CASCADE", quoteIdentifier(acme.Schema), quoteIdentifier(globex.Schema)))
+		m.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS public.%s", table))
+	})
+
+	insert(t, m, acme, "acme")
+	insert(t, m, globex, "globex")
+
+	expectBodies(t, acme, bodies(t, m, acme), "acme")
+	expectBodies(t, globex, bodies(t, m, globex), "globex")
+
+	var public int64
+	if err := m.db.Table("public." + table).Count(&public).Error; err != nil {
+		t.Fatal(err)
+	}
+	if public != 0 {
+		t.Errorf("%d rows written to the public schema", public)
+	}
+
+	// outside of a transaction there is no search path, Scoped fails closed
+	err := m.Scoped(WithTenant(context.Background(), acme)).Find(&[]isolationRow{}).Error
+	if !errors.Is(err, ErrTenantTransactionRequired) {
+		t.Errorf("Scoped outside of a transaction returned %v, want ErrTenantTransactionRequired", err)
+	}
+}
+
+func TestRowTenancyIsolatesTenants(t *testing.T) {
+	m := testModule(t, TenancyRow)
+	acme, globex := testTenant("acme"), testTenant("globex")
+
+	if err := m.db.AutoMigrate(&isolationRow{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.db.Migrator().DropTable(&isolationRow{})
+	})
+
+	insert(t, m, acme, "acme")
+	insert(t, m, globex, "globex")
+
+	expectBodies(t, acme, bodies(t, m, acme), "acme")
+	expectBodies(t, globex, bodies(t, m, globex), "globex")
+
+	// updates and deletes are filtered too
+	err := m.WithTransaction(WithTenant(context.Background(), acme), func(ctx context.Context) error {
+		return m.Scoped(ctx).Where("1 = 1").Delete(&isolationRow{}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBodies(t, globex, bodies(t, m, globex), "globex")
+
+	err = m.Scoped(context.Background()).Find(&[]isolationRow{}).Error
+	if !errors.Is(err, ErrTenantRequired) {
+		t.Errorf("Scoped without a tenant returned %v, want ErrTenantRequired", err)
+	}
+
+	var all []isolationRow
+	if err := m.AllTenants(context.Background()).Find(&all).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("AllTenants sees %d rows, want 1", len(all))
+	}
+
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Keys used to share request scoped values between middleware and domains.
+const (
+	ContextKeyUserID = "user_id"
+	ContextKeyUser   = "user"
+
+	ContextKeyRequestID = "request_id"
+)
+
+// Prefix shared by all versioned API routes.
+const APIPrefix = "/api/v1"
+
+// Returns the ID of the authenticated user stored on the echo context.
+// The boolean is false when the request is anonymous.
+func GetUserID(c echo.Context) (uuid.UUID, bool) {
+	id, ok := c.Get(ContextKeyUserID).(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Echo middleware rejecting anonymous requests with 401.
+// e.g. g.POST("", handler, server.RequireAuth)
+func RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := GetUserID(c); !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		}
+		return next(c)
+	}
+}
+
+// Returns a route group below APIPrefix.
+// e.g. m.APIGroup("/content") -> "/api/v1/content"
+func (m *Module) APIGroup(prefix string, middleware ...echo.MiddlewareFunc) *echo.Group {
+	return m.server.Group(APIPrefix+prefix, middleware...)
+}
+
+// Returns a route group below APIPrefix where every route requires an
+// authenticated user.
+func (m *Module) AuthGroup(prefix string, middleware ...echo.MiddlewareFunc) *echo.Group {
+	return m.APIGroup(prefix, append([]echo.MiddlewareFunc{RequireAuth}, middleware...)...)
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"funcedup/pkg/apperr"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// handleError renders every error returned by handlers and middleware as an
+// apperr.Error. Server errors are logged with the request ID so that reports
+// from clients can be matched with the logs, their cause is never sent.
+func (m *Module) handleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := apperr.From(err)
+
+	if appErr.Status >= http.StatusInternalServerError {
+		Logger(c, m.logger).Error("Request failed",
+			zap.String("method", c.Request().Method),
+			zap.String("route", c.Path()),
+			zap.Int("status", appErr.Status),
+			zap.Error(err),
+		)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(appErr.Status)
+	} else {
+		err = c.JSON(appErr.Status, appErr)
+	}
+	if err != nil {
+		m.logger.Error("Error writing error response", zap.Error(err))
+	}
+}
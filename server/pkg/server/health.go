@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Routes probed by docker-compose and orchestrators, outside of APIPrefix so
+// that tenant resolution and authentication never apply to them.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// HealthCheck is a named check reported by the health endpoints. Readiness
+// checks, the default, only affect /readyz. Liveness checks affect both and
+// should only fail when restarting the process is the fix.
+type HealthCheck struct {
+	Name     string
+	Liveness bool
+	Check    func(ctx context.Context) error
+}
+
+// HealthReport is the response body of the health endpoints.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+type HealthCheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// fx value group the server collects health checks from.
+const healthCheckGroup = `group:"health_checks"`
+
+// Annotates a constructor returning a HealthCheck so that its check is
+// registered with the server.
+// e.g. fx.Provide(server.AsHealthCheck(func(d *Domain) server.HealthCheck { ... }))
+func AsHealthCheck(constructor interface{}) interface{} {
+	return fx.Annotate(constructor, fx.ResultTags(healthCheckGroup))
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Registers a health check, checks with the same name are replaced.
+func (m *Module) AddHealthCheck(check HealthCheck) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	for i, existing := range m.healthChecks {
+		if existing.Name == check.Name {
+			m.healthChecks[i] = check
+			return
+		}
+	}
+	m.healthChecks = append(m.healthChecks, check)
+}
+
+// Returns true between the start of the server and the beginning of its
+// shutdown.
+func (m *Module) IsReady() bool {
+	return m.ready.Load()
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func (m *Module) setUpHealthRoutes(e *echo.Echo) {
+	e.GET(LivenessPath, m.liveness)
+	e.HEAD(LivenessPath, m.liveness)
+	e.GET(ReadinessPath, m.readiness)
+	e.HEAD(ReadinessPath, m.readiness)
+}
+
+// GET /healthz
+func (m *Module) liveness(c echo.Context) error {
+	report := m.runHealthChecks(c.Request().Context(), true)
+	return m.writeHealthReport(c, report)
+}
+
+// GET /readyz
+// Unavailable while shutting down, so no new traffic is routed to the server.
+func (m *Module) readiness(c echo.Context) error {
+	report := m.runHealthChecks(c.Request().Context(), false)
+	if !m.IsReady() {
+		report.Status = HealthStatusUnavailable
+		report.Checks["server"] = HealthCheckResult{Status: HealthStatusUnavailable, Error: "shutting down", Duration: "0s"}
+	}
+	return m.writeHealthReport(c, report)
+}
+
+// runHealthChecks runs the registered checks concurrently, each bounded by
+// server.health_check_timeout.
+func (m *Module) runHealthChecks(ctx context.Context, livenessOnly bool) HealthReport {
+	m.healthMu.RLock()
+	checks := make([]HealthCheck, 0, len(m.healthChecks))
+	for _, check := range m.healthChecks {
+		if check.Liveness || !livenessOnly {
+			checks = append(checks, check)
+		}
+	}
+	m.healthMu.RUnlock()
+
+	report := HealthReport{
+		Status: HealthStatusOK,
+		Checks: make(map[string]HealthCheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, m.config.HealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			result := HealthCheckResult{Status: HealthStatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = HealthStatusUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil {
+				report.Status = HealthStatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (m *Module) writeHealthReport(c echo.Context, report HealthReport) error {
+	status := http.StatusOK
+	if report.Status != HealthStatusOK {
+		status = http.StatusServiceUnavailable
+		m.logger.Warn("Health check failed", zap.String("path", c.Path()), zap.Any("checks", report.Checks))
+	}
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(status)
+	}
+	return c.JSON(status, report)
+}
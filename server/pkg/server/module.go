@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"funcedup/pkg/config"
 	"funcedup/pkg/util"
 
 	"github.com/go-playground/validator/v10"
@@ -23,25 +28,42 @@ type Module struct {
 	logger *zap.Logger
 	scope  string
 	server *echo.Echo
+
+	ready        atomic.Bool
+	cors         atomic.Pointer[echo.MiddlewareFunc]
+	healthMu     sync.RWMutex
+	healthChecks []HealthCheck
 }
 
 type Params struct {
 	fx.In
 	Lifecycle fx.Lifecycle
 	Logger    *zap.Logger
+
+	HealthChecks []HealthCheck `group:"health_checks"`
+
+	// reloads the CORS settings when config.watch is enabled
+	Config *config.Module `optional:"true"`
 }
 
 type Config struct {
-	AllowHeaders string
-	AllowMethods string
-	AllowOrigins string
+	AllowHeaders string `mapstructure:"allow_headers"`
+	AllowMethods string `mapstructure:"allow_methods"`
+	AllowOrigins string `mapstructure:"allow_origins"`
 
-	CSRFProtection bool
-	CSRFSecure     bool
-	CSRFDomain     string
+	CSRFProtection bool   `mapstructure:"csrf_protection"`
+	CSRFSecure     bool   `mapstructure:"csrf_secure"`
+	CSRFDomain     string `mapstructure:"csrf_domain"`
 
-	Host           string
-	Port           int
+	IsMultiTenant            bool   `mapstructure:"is_multi_tenant"`
+	TenantIdentifier         string `mapstructure:"tenant_identifier"`
+	TenantIdentifierLocation string `mapstructure:"tenant_identifier_location" validate:"oneofci=header cookie subdomain path"`
+
+	HealthCheckTimeout time.Duration `mapstructure:"health_check_timeout" validate:"gt=0"`
+	ShutdownDelay      time.Duration `mapstructure:"shutdown_delay" validate:"gte=0"`
+
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port" validate:"min=1,max=65535"`
 	ServerLogLevel string
 }
 
@@ -58,16 +80,37 @@ const (
 	DefaultTenantIdentifier         = "X-Tenant"
 	DefaultTenantIdentifierLocation = "header"
 
+	DefaultHealthCheckTimeout = 2 * time.Second
+	DefaultShutdownDelay      = 0 * time.Second
+
 	DefaultHost           = "localhost"
 	DefaultPort           = 3001
 	DefaultServerLogLevel = "PROD"
 )
 
 // Custom validator for Echo using go-playground/validator.
+// Fields are reported by their json or query name, see apperr.Validation.
 type CustomValidator struct {
 	validator *validator.Validate
 }
 
+func NewCustomValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "query", "param"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	return &CustomValidator{validator: v}
+}
+
 func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.validator.Struct(i)
 }
@@ -87,6 +130,13 @@ func InjectModule(scope string) fx.Option {
 			m.logger = m.setupLogger(scope, p)
 			m.server = m.setupServer()
 
+			for _, check := range p.HealthChecks {
+				m.AddHealthCheck(check)
+			}
+			if p.Config != nil {
+				m.subscribeConfig(scope, p.Config)
+			}
+
 			return m
 		}),
 		fx.Invoke(func(m *Module, p Params) {
@@ -115,35 +165,32 @@ func NewServer(scope string, logger *zap.Logger) *Module {
 // ! INTERNAL ---------------------------------------------------------------
 
 func (m *Module) setupConfig(scope string) *Config {
-	// searches for pattern: "scope.key"
-	viper.SetDefault(util.GetConfigPath(scope, "allow_headers"), DefaultAllowHeaders)
-	viper.SetDefault(util.GetConfigPath(scope, "allow_methods"), DefaultAllowMethods)
-	viper.SetDefault(util.GetConfigPath(scope, "allow_origins"), DefaultAllowOrigins)
+	c := &Config{
+		AllowHeaders: DefaultAllowHeaders,
+		AllowMethods: DefaultAllowMethods,
+		AllowOrigins: DefaultAllowOrigins,
 
-	viper.SetDefault(util.GetConfigPath(scope, "csrf_protection"), DefaultCSRFProtection)
-	viper.SetDefault(util.GetConfigPath(scope, "csrf_secure"), DefaultCSRFSecure)
-	viper.SetDefault(util.GetConfigPath(scope, "csrf_domain"), DefaultCSRFDomain)
+		CSRFProtection: DefaultCSRFProtection,
+		CSRFSecure:     DefaultCSRFSecure,
+		CSRFDomain:     DefaultCSRFDomain,
 
-	viper.SetDefault(util.GetConfigPath(scope, "is_multi_tenant"), DefaultIsMultiTenant)
-	viper.SetDefault(util.GetConfigPath(scope, "tenant_identifier"), DefaultTenantIdentifier)
-	viper.SetDefault(util.GetConfigPath(scope, "tenant_identifier_location"), DefaultTenantIdentifierLocation)
+		IsMultiTenant:            DefaultIsMultiTenant,
+		TenantIdentifier:         DefaultTenantIdentifier,
+		TenantIdentifierLocation: DefaultTenantIdentifierLocation,
 
-	viper.SetDefault(util.GetConfigPath(scope, "host"), DefaultHost)
-	viper.SetDefault(util.GetConfigPath(scope, "port"), DefaultPort)
+		HealthCheckTimeout: DefaultHealthCheckTimeout,
+		ShutdownDelay:      DefaultShutdownDelay,
 
-	return &Config{
-		AllowHeaders: viper.GetString(util.GetConfigPath(scope, "allow_headers")),
-		AllowMethods: viper.GetString(util.GetConfigPath(scope, "allow_methods")),
-		AllowOrigins: viper.GetString(util.GetConfigPath(scope, "allow_origins")),
+		Host: DefaultHost,
+		Port: DefaultPort,
+	}
+	// searches for pattern: "scope.key"
+	config.Load(scope, c)
 
-		CSRFProtection: viper.GetBool(util.GetConfigPath(scope, "csrf_protection")),
-		CSRFSecure:     viper.GetBool(util.GetConfigPath(scope, "csrf_secure")),
-		CSRFDomain:     viper.GetString(util.GetConfigPath(scope, "csrf_domain")),
+	c.TenantIdentifierLocation = strings.ToLower(c.TenantIdentifierLocation)
+	c.ServerLogLevel = viper.GetString(util.GetConfigPath("global", "log_level"))
 
-		Host:           viper.GetString(util.GetConfigPath(scope, "host")),
-		Port:           viper.GetInt(util.GetConfigPath(scope, "port")),
-		ServerLogLevel: viper.GetString(util.GetConfigPath("global", "log_level")),
-	}
+	return c
 }
 
 func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
@@ -151,26 +198,35 @@ func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
 	return logger
 }
 
+// setupServer registers the middlewares that must run before those of the
+// domains, e.g. tenant and auth, as Pre middlewares. Pre runs before routing
+// and in order of registration.
 func (m *Module) setupServer() *echo.Echo {
 	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
+	e.Pre(requestIDMiddleware)
+	if strings.EqualFold(m.config.ServerLogLevel, "debug") ||
+		strings.EqualFold(m.config.ServerLogLevel, "dev") {
+		m.setUpRequestLoggerMiddleware(e)
+	}
+	m.setUpCorsMiddleware(e)
+	m.setUpCSRFMiddleware(e)
+	e.Validator = NewCustomValidator()
+	e.HTTPErrorHandler = m.handleError
+	m.setUpHealthRoutes(e)
 	return e
 }
 
 func (m *Module) onStart(context.Context) error {
 	m.logger.Info("Starting server")
 
-	m.setUpCorsMiddleware()
-	m.setUpCSRFMiddleware()
-
-	if strings.EqualFold(m.config.ServerLogLevel, "debug") ||
-		strings.EqualFold(m.config.ServerLogLevel, "dev") {
-		m.setUpRequestLoggerMiddleware()
+	if err := m.validateTenantConfig(); err != nil {
+		return err
 	}
 
 	// server must be started in a goroutine to prevent blocking the hooks
 	// context will timeout otherwise
 	go m.startServer(true, false)
+	m.ready.Store(true)
 
 	if strings.EqualFold(m.config.ServerLogLevel, "debug") {
 		m.logConfigurations()
@@ -180,6 +236,14 @@ func (m *Module) onStart(context.Context) error {
 }
 
 func (m *Module) onStop(context.Context) error {
+	// report unavailable first, so that load balancers stop routing requests
+	// before the listener closes
+	m.ready.Store(false)
+	if m.config.ShutdownDelay > 0 {
+		m.logger.Info("Draining server", zap.Duration("shutdown_delay", m.config.ShutdownDelay))
+		time.Sleep(m.config.ShutdownDelay)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -192,32 +256,34 @@ func (m *Module) onStop(context.Context) error {
 	return nil
 }
 
-func (m *Module) setUpCorsMiddleware() {
+// setUpCorsMiddleware delegates to the current CORS middleware, replaced by
+// setCors when the settings are reloaded.
+func (m *Module) setUpCorsMiddleware(e *echo.Echo) {
+	m.setCors(m.config.AllowOrigins, m.config.AllowMethods, m.config.AllowHeaders)
 
-	// Helper function to split and trim spaces
-	splitAndTrim := func(s string) []string {
-		parts := strings.Split(s, ",")
-		for i, part := range parts {
-			parts[i] = strings.TrimSpace(part)
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return (*m.cors.Load())(next)(c)
 		}
-		return parts
-	}
+	})
+}
 
+func (m *Module) setCors(allowOrigins string, allowMethods string, allowHeaders string) {
 	corsConfig := middleware.CORSConfig{
-		AllowOrigins:     splitAndTrim(m.config.AllowOrigins),
-		AllowMethods:     splitAndTrim(m.config.AllowMethods),
-		AllowHeaders:     splitAndTrim(m.config.AllowHeaders),
+		AllowOrigins:     splitAndTrim(allowOrigins),
+		AllowMethods:     splitAndTrim(allowMethods),
+		AllowHeaders:     splitAndTrim(allowHeaders),
 		AllowCredentials: true,
 	}
 
 	// Default values if unspecified
-	if m.config.AllowOrigins == "" || m.config.AllowOrigins == "*" {
+	if allowOrigins == "" || allowOrigins == "*" {
 		corsConfig.AllowOrigins = []string{"*"}
 	}
-	if m.config.AllowMethods == "" || m.config.AllowMethods == "*" {
+	if allowMethods == "" || allowMethods == "*" {
 		corsConfig.AllowMethods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete}
 	}
-	if m.config.AllowHeaders == "" || m.config.AllowHeaders == "*" {
+	if allowHeaders == "" || allowHeaders == "*" {
 		corsConfig.AllowHeaders = []string{
 			"accept",
 			"content-type",
@@ -230,16 +296,66 @@ func (m *Module) setUpCorsMiddleware() {
 			"expires",
 			"set-cookie",
 			"cookie",
-			"X-Tenant", // tenant identifier
-			"jwt",      // jwt token for authentication
-			"token",    // confirmation tokens
+			"x-request-id",
+			m.config.TenantIdentifier, // tenant identifier header
+			"jwt",                     // jwt token for authentication
+			"token",                   // confirmation tokens
+		}
+	}
+
+	cors := middleware.CORSWithConfig(corsConfig)
+	m.cors.Store(&cors)
+}
+
+// subscribeConfig applies reloaded CORS settings, origins have to be "*" or
+// scheme://host[:port].
+func (m *Module) subscribeConfig(scope string, c *config.Module) {
+	c.Subscribe(config.Subscription{
+		Keys: []string{
+			util.GetConfigPath(scope, "allow_origins"),
+			util.GetConfigPath(scope, "allow_methods"),
+			util.GetConfigPath(scope, "allow_headers"),
+		},
+		Validate: func(next *viper.Viper) error {
+			return validateOrigins(next.GetString(util.GetConfigPath(scope, "allow_origins")))
+		},
+		Apply: func(next *viper.Viper, _ []config.Change) {
+			m.setCors(
+				next.GetString(util.GetConfigPath(scope, "allow_origins")),
+				next.GetString(util.GetConfigPath(scope, "allow_methods")),
+				next.GetString(util.GetConfigPath(scope, "allow_headers")),
+			)
+			m.logger.Info("CORS settings reloaded")
+		},
+	})
+}
+
+func validateOrigins(allowOrigins string) error {
+	if allowOrigins == "" {
+		return nil
+	}
+	for _, origin := range splitAndTrim(allowOrigins) {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("invalid allow_origins entry %q, expected scheme://host[:port]", origin)
 		}
 	}
+	return nil
+}
 
-	m.server.Use(middleware.CORSWithConfig(corsConfig))
+// Helper function to split and trim spaces
+func splitAndTrim(s string) []string {
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return parts
 }
 
-func (m *Module) setUpCSRFMiddleware() {
+func (m *Module) setUpCSRFMiddleware(e *echo.Echo) {
 	// defaults to not using CSRF protection if unspecified
 	if !m.config.CSRFProtection {
 		return
@@ -269,56 +385,55 @@ func (m *Module) setUpCSRFMiddleware() {
 			return false
 		},
 	}
-	m.server.Use(middleware.CSRFWithConfig(CSRFConfig))
+	e.Pre(middleware.CSRFWithConfig(CSRFConfig))
 }
 
-func (m *Module) setUpRequestLoggerMiddleware() {
+func (m *Module) setUpRequestLoggerMiddleware(e *echo.Echo) {
 	// Defaults to PROD log level if unspecified
 	// Valid log levels: DEV, PROD, DEBUG
 	requestLoggerConfig := middleware.RequestLoggerConfig{
-		LogProtocol:   true,
-		LogMethod:     true,
-		LogURI:        true,
-		LogStatus:     true,
-		LogRequestID:  true,
-		LogRemoteIP:   true,
-		LogLatency:    true,
-		LogError:      true,
+		LogProtocol: true,
+		LogMethod:   true,
+		LogURI:      true,
+		LogStatus:   true,
+		LogRemoteIP: true,
+		LogLatency:  true,
+		LogError:    true,
+		// runs before routing, the error handler sets the status
+		HandleError:   true,
 		LogValuesFunc: m.logRequest,
 	}
 
-	m.server.Use(middleware.RequestLoggerWithConfig(requestLoggerConfig))
+	e.Pre(middleware.RequestLoggerWithConfig(requestLoggerConfig))
 }
 
 // helper function for setUpRequestLoggerMiddleware
 func (m *Module) logRequest(c echo.Context, v middleware.RequestLoggerValues) error {
 
 	lowerCaseLogLevel := strings.ToLower(m.config.ServerLogLevel)
+	requestLogger := Logger(c, m.logger)
 
 	switch lowerCaseLogLevel {
 	case "dev":
-		m.logger.Info("request",
+		requestLogger.Info("request",
 			zap.String("method", v.Method),
 			zap.String("URI", v.URI),
-			zap.String("request_id", v.RequestID),
 			zap.Int("status", v.Status),
 			zap.Any("error", v.Error),
 		)
 	case "prod":
-		m.logger.Info("request",
+		requestLogger.Info("request",
 			zap.String("URI", v.URI),
 			zap.Int("status", v.Status),
 			zap.Any("error", v.Error),
-			zap.String("request_id", v.RequestID),
 			zap.Duration("latency", v.Latency),
 		)
 	case "debug":
-		m.logger.Debug("request",
+		requestLogger.Debug("request",
 			zap.String("method", v.Method),
 			zap.String("URI", v.URI),
 			zap.Int("status", v.Status),
 			zap.String("remote_ip", v.RemoteIP),
-			zap.String("request_id", v.RequestID),
 			zap.Duration("latency", v.Latency),
 			zap.String("protocol", v.Protocol),
 			zap.Any("error", v.Error),
@@ -349,6 +464,8 @@ func (m *Module) logConfigurations() {
 	m.logger.Debug("----- Server Configuration -----")
 	m.logger.Debug("Host", zap.String("Host", m.config.Host))
 	m.logger.Debug("Port", zap.Int("Port", m.config.Port))
+	m.logger.Debug("HealthCheckTimeout", zap.Duration("HealthCheckTimeout", m.config.HealthCheckTimeout))
+	m.logger.Debug("ShutdownDelay", zap.Duration("ShutdownDelay", m.config.ShutdownDelay))
 
 	m.logger.Debug("----- Cors Configuration -----")
 	m.logger.Debug("AllowOrigins", zap.String("AllowOrigins", m.config.AllowOrigins))
@@ -365,6 +482,13 @@ func (m *Module) logConfigurations() {
 		m.logger.Debug("CSRFCookieSameSite", zap.String("CSRFCookieSameSite", "Default"))
 		m.logger.Debug("CSRFCookieHTTPOnly", zap.Bool("CSRFCookieHTTPOnly", true))
 	}
+
+	m.logger.Debug("----- Tenant Configuration -----")
+	m.logger.Debug("IsMultiTenant", zap.Bool("IsMultiTenant", m.config.IsMultiTenant))
+	if m.config.IsMultiTenant {
+		m.logger.Debug("TenantIdentifier", zap.String("TenantIdentifier", m.config.TenantIdentifier))
+		m.logger.Debug("TenantIdentifierLocation", zap.String("TenantIdentifierLocation", m.config.TenantIdentifierLocation))
+	}
 }
 
 //! EXTERNAL ---------------------------------------------------------------
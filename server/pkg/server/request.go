@@ -0,0 +1,63 @@
+package server
+
+import (
+	"funcedup/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Longest X-Request-ID accepted from clients, longer IDs are replaced.
+const maxRequestIDLength = 128
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns the ID of the request, see requestIDMiddleware.
+func RequestID(c echo.Context) string {
+	id, _ := c.Get(ContextKeyRequestID).(string)
+	return id
+}
+
+// Returns a child of base carrying the request ID, user and tenant of the
+// request, as well as its trace.
+// e.g. server.Logger(c, d.logger).Error("Error creating note", zap.Error(err))
+func Logger(c echo.Context, base *zap.Logger) *zap.Logger {
+	return logger.Ctx(c.Request().Context(), base)
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+// requestIDMiddleware honors the X-Request-ID of the caller, e.g. a proxy,
+// or generates one. The ID is echoed in the response and added to the
+// request context for logger.Ctx.
+func requestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		id := req.Header.Get(echo.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(ContextKeyRequestID, id)
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+		c.SetRequest(req.WithContext(logger.WithFields(req.Context(), zap.String("request_id", id))))
+
+		return next(c)
+	}
+}
+
+// validRequestID only accepts printable ASCII, so that client supplied IDs
+// cannot forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
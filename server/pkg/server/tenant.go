@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Where the tenant identifier of a request is read from, see
+// server.tenant_identifier_location.
+const (
+	TenantLocationHeader    = "header"    // header named server.tenant_identifier
+	TenantLocationCookie    = "cookie"    // cookie named server.tenant_identifier
+	TenantLocationSubdomain = "subdomain" // first label of the host, e.g. acme.funcedup.com
+	TenantLocationPath      = "path"      // first path segment, e.g. /acme/api/v1/content
+)
+
+// Key the resolved tenant is stored under in the echo context.
+const ContextKeyTenant = "tenant"
+
+// Returns true when server.is_multi_tenant is set.
+func (m *Module) IsMultiTenant() bool {
+	return m.config.IsMultiTenant
+}
+
+// Returns the tenant identifier of the request from the configured location,
+// or an empty string if the request does not carry one.
+// For the path location the identifier is stripped from the request path so
+// routes are matched without it, call it from an echo Pre middleware.
+// e.g. /acme/api/v1/content -> "acme", routed as /api/v1/content
+func (m *Module) TenantIdentifier(c echo.Context) string {
+	req := c.Request()
+
+	switch m.config.TenantIdentifierLocation {
+	case TenantLocationHeader:
+		return strings.TrimSpace(req.Header.Get(m.config.TenantIdentifier))
+	case TenantLocationCookie:
+		if cookie, err := c.Cookie(m.config.TenantIdentifier); err == nil {
+			return strings.TrimSpace(cookie.Value)
+		}
+	case TenantLocationSubdomain:
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		// bare domains and ip addresses carry no tenant
+		if labels := strings.Split(host, "."); len(labels) > 2 && net.ParseIP(host) == nil {
+			return strings.ToLower(labels[0])
+		}
+	case TenantLocationPath:
+		if isAPIPath(req.URL.Path) {
+			return ""
+		}
+		// only API routes are prefixed, e.g. /healthz and /metrics are not
+		segment, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+		if segment == "" || !isAPIPath("/"+rest) {
+			return ""
+		}
+		req.URL.Path = "/" + rest
+		req.URL.RawPath = ""
+		return segment
+	}
+	return ""
+}
+
+func isAPIPath(path string) bool {
+	return path == APIPrefix || strings.HasPrefix(path, APIPrefix+"/")
+}
+
+func (m *Module) validateTenantConfig() error {
+	if !m.config.IsMultiTenant {
+		return nil
+	}
+
+	switch m.config.TenantIdentifierLocation {
+	case TenantLocationHeader, TenantLocationCookie:
+		if m.config.TenantIdentifier == "" {
+			return fmt.Errorf("server.tenant_identifier is required for the %s location", m.config.TenantIdentifierLocation)
+		}
+	case TenantLocationSubdomain, TenantLocationPath:
+	default:
+		return fmt.Errorf("invalid server.tenant_identifier_location %q, expected header, cookie, subdomain or path", m.config.TenantIdentifierLocation)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestTenantIdentifierFromPath(t *testing.T) {
+	m := &Module{config: &Config{IsMultiTenant: true, TenantIdentifierLocation: TenantLocationPath}}
+	e := echo.New()
+
+	tests := []struct {
+		path   string
+		tenant string
+		routed string
+	}{
+		{"/acme/api/v1/content", "acme", "/api/v1/content"},
+		{"/acme/api/v1", "acme", "/api/v1"},
+		{"/api/v1/content", "", "/api/v1/content"},
+		{"/healthz", "", "/healthz"},
+		{"/readyz", "", "/readyz"},
+		{"/metrics", "", "/metrics"},
+		{"/acme/metrics", "", "/acme/metrics"},
+		{"/acme/api/v1x", "", "/acme/api/v1x"},
+		{"/", "", "/"},
+	}
+	for _, test := range tests {
+		c := e.NewContext(httptest.NewRequest("GET", test.path, nil), httptest.NewRecorder())
+
+		if tenant := m.TenantIdentifier(c); tenant != test.tenant {
+			t.Errorf("%s: got tenant %q, want %q", test.path, tenant, test.tenant)
+		}
+		if routed := c.Request().URL.Path; routed != test.routed {
+			t.Errorf("%s: routed as %s, want %s", test.path, routed, test.routed)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"net/http"
+
+	"funcedup/pkg/apperr"
+	"funcedup/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attribute of server spans holding the ID of the request
+const requestIDKey = "request_id"
+
+// middleware starts a server span per request, continuing the trace of the
+// caller when a traceparent header is sent. The span is stored on the request
+// context, so everything using c.Request().Context() joins the trace.
+func (m *Module) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+		route := c.Path()
+		name := req.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx, span := m.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(req.URL.Path),
+				// the field logs carry, see server.Logger
+				attribute.String(requestIDKey, server.RequestID(c)),
+			),
+		)
+		defer span.End()
+
+		c.SetRequest(req.WithContext(ctx))
+		// lets clients and proxies correlate their logs with the trace
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(c.Response().Header()))
+
+		err := next(c)
+
+		status := c.Response().Status
+		if err != nil {
+			status = apperr.From(err).Status
+			span.RecordError(err)
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"funcedup/pkg/config"
+	"funcedup/pkg/pgconn"
+	"funcedup/pkg/server"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+type Module struct {
+	config   *Config
+	logger   *zap.Logger
+	scope    string
+	params   Params
+	provider trace.TracerProvider
+	tracer   trace.Tracer
+
+	// nil when tracing is disabled
+	sdkProvider *sdktrace.TracerProvider
+}
+
+type Params struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Logger    *zap.Logger
+	Server    *server.Module
+	DB        *pgconn.Module
+
+	// Replaces the configured exporter, spans are then exported synchronously.
+	// e.g. fx.Supply(fx.Annotate(tracetest.NewInMemoryExporter(), fx.As(new(sdktrace.SpanExporter))))
+	Exporter sdktrace.SpanExporter `optional:"true"`
+}
+
+type Config struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Exporter    string  `mapstructure:"exporter" validate:"oneofci=otlp stdout"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"service_name" validate:"required"`
+	SampleRatio float64 `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+}
+
+// Span exporters selectable with tracing.exporter.
+const (
+	ExporterOTLP   = "otlp"   // OTLP over http, e.g. to an otel collector or jaeger
+	ExporterStdout = "stdout" // pretty printed spans, for local debugging
+)
+
+const (
+	DefaultEnabled     = false
+	DefaultExporter    = ExporterOTLP
+	DefaultEndpoint    = "localhost:4318"
+	DefaultInsecure    = true
+	DefaultServiceName = "funcedup"
+	DefaultSampleRatio = 1.0
+
+	// name of the tracer spans of this module are created with
+	instrumentationName = "funcedup/pkg/tracing"
+)
+
+//! MODULE ---------------------------------------------------------------
+
+// Provides the module to the fx framework
+func InjectModule(scope string) fx.Option {
+	return fx.Module(
+		scope,
+		fx.Provide(func(p Params) (*Module, error) {
+
+			m := &Module{scope: scope, params: p}
+			m.config = m.setupConfig(scope)
+			m.logger = m.setupLogger(scope, p)
+
+			if err := m.setupProvider(); err != nil {
+				return nil, err
+			}
+			if err := m.setupInstrumentation(); err != nil {
+				return nil, err
+			}
+
+			return m, nil
+		}),
+		fx.Invoke(func(m *Module, p Params) {
+			p.Lifecycle.Append(
+				fx.Hook{
+					OnStart: m.onStart,
+					OnStop:  m.onStop,
+				},
+			)
+		}),
+	)
+}
+
+//! INTERNAL ---------------------------------------------------------------
+
+func (m *Module) setupConfig(scope string) *Config {
+	c := &Config{
+		Enabled:     DefaultEnabled,
+		Exporter:    DefaultExporter,
+		Endpoint:    DefaultEndpoint,
+		Insecure:    DefaultInsecure,
+		ServiceName: DefaultServiceName,
+		SampleRatio: DefaultSampleRatio,
+	}
+	config.Load(scope, c)
+
+	c.Exporter = strings.ToLower(c.Exporter)
+	return c
+}
+
+func (m *Module) setupLogger(scope string, p Params) *zap.Logger {
+	logger := p.Logger.Named("[" + scope + "]")
+	return logger
+}
+
+// setupProvider builds the tracer provider and installs it, together with the
+// W3C trace context propagator, as the otel globals.
+// Without tracing.enabled and an injected exporter a no-op provider is used.
+func (m *Module) setupProvider() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !m.config.Enabled && m.params.Exporter == nil {
+		m.provider = noop.NewTracerProvider()
+		m.tracer = m.provider.Tracer(instrumentationName)
+		return nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(m.config.ServiceName),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(m.config.SampleRatio))),
+	}
+	if m.params.Exporter != nil {
+		options = append(options, sdktrace.WithSyncer(m.params.Exporter))
+	} else {
+		exporter, err := m.newExporter()
+		if err != nil {
+			return err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	m.sdkProvider = sdktrace.NewTracerProvider(options...)
+	m.provider = m.sdkProvider
+	m.tracer = m.provider.Tracer(instrumentationName)
+	otel.SetTracerProvider(m.provider)
+
+	return nil
+}
+
+func (m *Module) newExporter() (sdktrace.SpanExporter, error) {
+	switch m.config.Exporter {
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(m.config.Endpoint)}
+		if m.config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		// connects lazily, an unreachable collector only drops spans
+		return otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("invalid tracing.exporter %q, expected %s or %s", m.config.Exporter, ExporterOTLP, ExporterStdout)
+	}
+}
+
+// setupInstrumentation adds server spans to every request and client spans to
+// every GORM statement.
+func (m *Module) setupInstrumentation() error {
+	m.params.Server.GetServer().Use(m.middleware)
+	return m.params.DB.GetDB().Use(&gormPlugin{tracer: m.tracer})
+}
+
+func (m *Module) onStart(ctx context.Context) error {
+	m.logger.Info("Starting tracing module.")
+
+	if strings.EqualFold(viper.GetString("global.log_level"), "debug") {
+		m.logConfigurations()
+	}
+
+	return nil
+}
+
+// onStop flushes spans still waiting in the batcher.
+func (m *Module) onStop(ctx context.Context) error {
+	m.logger.Info("Stopping tracing module.")
+
+	if m.sdkProvider == nil {
+		return nil
+	}
+	if err := m.sdkProvider.Shutdown(ctx); err != nil {
+		m.logger.Error("Error flushing spans", zap.Error(err))
+	}
+	return nil
+}
+
+func (m *Module) logConfigurations() {
+	m.logger.Debug("----- Tracing Configuration -----")
+	m.logger.Debug("Enabled", zap.Bool("enabled", m.config.Enabled))
+	m.logger.Debug("Exporter", zap.String("exporter", m.config.Exporter))
+	m.logger.Debug("Endpoint", zap.String("endpoint", m.config.Endpoint))
+	m.logger.Debug("Insecure", zap.Bool("insecure", m.config.Insecure))
+	m.logger.Debug("ServiceName", zap.String("service_name", m.config.ServiceName))
+	m.logger.Debug("SampleRatio", zap.Float64("sample_ratio", m.config.SampleRatio))
+}
+
+//! EXTERNAL ---------------------------------------------------------------
+
+// Returns the tracer of the module, domains use it for spans of their own.
+// e.g. ctx, span := d.params.Tracing.Tracer().Start(ctx, "content.render")
+func (m *Module) Tracer() trace.Tracer {
+	return m.tracer
+}
+
+// Returns the tracer provider, a no-op provider when tracing is disabled.
+func (m *Module) TracerProvider() trace.TracerProvider {
+	return m.provider
+}
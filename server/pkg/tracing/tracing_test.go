@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"funcedup/pkg/server"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type tracedRow struct {
+	ID   uint
+	Body string
+}
+
+// testModule wires the middleware and the GORM plugin like
+// setupInstrumentation, with spans recorded by exporter. GORM runs in dry run
+// mode, statements are built and traced but never sent to a database.
+func testModule(t *testing.T, exporter *tracetest.InMemoryExporter) (*echo.Echo, *gorm.DB) {
+	t.Helper()
+
+	m := &Module{
+		config: &Config{ServiceName: DefaultServiceName, SampleRatio: DefaultSampleRatio},
+		logger: zap.NewNop(),
+		params: Params{Exporter: exporter},
+	}
+	if err := m.setupProvider(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.sdkProvider.Shutdown(context.Background()) })
+
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(&gormPlugin{tracer: m.tracer}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	// stands in for the request ID middleware of the server module
+	e.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(server.ContextKeyRequestID, c.Request().Header.Get(echo.HeaderXRequestID))
+			return next(c)
+		}
+	})
+	e.Use(m.middleware)
+	return e, db
+}
+
+func attributeValue(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestRequestSpanHasGormChild(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	e, db := testModule(t, exporter)
+
+	e.GET("/rows/:id", func(c echo.Context) error {
+		var row tracedRow
+		if err := db.WithContext(c.Request().Context()).First(&row, "id = ?", c.Param("id")).Error; err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/rows/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "request-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2: %v", len(spans), spans)
+	}
+
+	var request, statement tracetest.SpanStub
+	for _, span := range spans {
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			request = span
+		case trace.SpanKindClient:
+			statement = span
+		}
+	}
+
+	if request.Name != "GET /rows/:id" {
+		t.Errorf("server span is named %q, want %q", request.Name, "GET /rows/:id")
+	}
+	expected := map[attribute.Key]attribute.Value{
+		semconv.HTTPRouteKey:              attribute.StringValue("/rows/:id"),
+		semconv.HTTPResponseStatusCodeKey: attribute.IntValue(http.StatusNoContent),
+		requestIDKey:                      attribute.StringValue("request-1"),
+	}
+	for key, want := range expected {
+		got, ok := attributeValue(request, key)
+		if !ok || got != want {
+			t.Errorf("server span attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	if statement.Name != "gorm.query traced_rows" {
+		t.Errorf("statement span is named %q, want %q", statement.Name, "gorm.query traced_rows")
+	}
+	if statement.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Error("statement span is not a child of the server span")
+	}
+	if statement.SpanContext.TraceID() != request.SpanContext.TraceID() {
+		t.Error("statement span belongs to another trace")
+	}
+	if query, _ := attributeValue(statement, semconv.DBQueryTextKey); query.AsString() == "" {
+		t.Error("statement span has no query text")
+	}
+}
+
+// the span of a failed request carries the status the error is rendered with
+func TestRequestSpanRecordsErrorStatus(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	e, _ := testModule(t, exporter)
+
+	e.GET("/missing", func(c echo.Context) error {
+		return gorm.ErrRecordNotFound
+	})
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	status, _ := attributeValue(spans[0], semconv.HTTPResponseStatusCodeKey)
+	if status.AsInt64() != http.StatusNotFound {
+		t.Errorf("status attribute %d, want %d", status.AsInt64(), http.StatusNotFound)
+	}
+	if len(spans[0].Events) == 0 {
+		t.Error("error is not recorded on the span")
+	}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"gorm.io/gorm"
+)
+
+// Pagination holds the page/page_size query parameters of a list request.
+type Pagination struct {
+	Page     int `query:"page" json:"page"`
+	PageSize int `query:"page_size" json:"pageSize"`
+}
+
+// Page wraps a list of items together with the pagination used to fetch them.
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
+	Total    int64 `json:"total"`
+}
+
+// Normalize clamps the pagination to sane values.
+// e.g. Pagination{Page: 0, PageSize: 500}.Normalize(20, 100) -> {Page: 1, PageSize: 100}
+func (p Pagination) Normalize(defaultPageSize int, maxPageSize int) Pagination {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize < 1 {
+		p.PageSize = defaultPageSize
+	}
+	if maxPageSize > 0 && p.PageSize > maxPageSize {
+		p.PageSize = maxPageSize
+	}
+	return p
+}
+
+// Offset returns the number of rows to skip for the current page.
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Scope returns a GORM scope applying limit and offset.
+// e.g. db.Scopes(p.Scope()).Find(&items)
+func (p Pagination) Scope() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Offset(p.Offset()).Limit(p.PageSize)
+	}
+}
+
+// NewPage builds a Page from the fetched items.
+func NewPage[T any](items []T, p Pagination, total int64) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return Page[T]{
+		Items:    items,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		Total:    total,
+	}
+}